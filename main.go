@@ -4,31 +4,63 @@ import (
 	"streambot"
 	"io/ioutil"
 	"encoding/json"
+	"errors"
 	"os/signal"
 	stdlog "log"
     "os"
     "github.com/op/go-logging"
     "github.com/jessevdk/go-flags"
     "fmt"
+    "time"
+    "net/http"
+    _ "github.com/mattn/go-sqlite3"
+    _ "github.com/lib/pq"
 )
 
 type Options struct {
     ConfigFilepath string `short:"c" long:"config" description:"File path of configuration file"`
 }
 
+type CheckCommand struct {
+	Repair bool `long:"repair" description:"Merge Channels with duplicate uids into a single one"`
+}
+
 var log = logging.MustGetLogger("streambot-api")
 
 type ServerConfig struct {
-	Port int `json:"port"`
+	Port 					int `json:"port"`
+	MaxSubscriptionDepth 	int `json:"max_subscription_depth"`
 }
 
 type StatsConfig struct {
+	// Drops all metrics instead of sending or exposing them
+	Disabled bool `json:"disabled"`
+	// StatsD daemon receiving the metrics, `localhost:8125` unless configured otherwise
+	Host string `json:"host"`
 	Port int `json:"port"`
+	// Prefix of all metric keys, the host name unless configured otherwise
+	Prefix string `json:"prefix"`
+	// Fraction of metrics to send, either for all or per metric key
+	SampleRate float64 `json:"sample_rate"`
+	SampleRates map[string]float64 `json:"sample_rates"`
+	// Port of the admin server exposing metrics to be scraped by Prometheus at `/metrics` and 
+	// rebuilding the search index on `POST /search-index/rebuild`, which is not started unless 
+	// configured
+	AdminPort int `json:"admin_port"`
+	// Seconds between logging the p50, p95 and p99 latencies of the timings, which are not 
+	// logged unless configured
+	SummaryInterval int `json:"summary_interval"`
 }
 
 type DatabaseConfig struct {
+	// Either `rexster` (default), `bolt` for a single database file within DataDir, `sqlite3` or 
+	// `postgres` for a relational database at DSN or `memory` for a database that lives in process 
+	// memory only
+	Driver string `json:"driver"`
 	Hosts []string `json:"hosts"`
 	Graph string `json:"graph"`
+	DataDir string `json:"data_dir"`
+	DSN string `json:"dsn"`
 }
 
 type Config struct {
@@ -36,6 +68,9 @@ type Config struct {
 	Database 			DatabaseConfig 	`json:"database"`
 	Stats	 			StatsConfig		`json:"stats"`
 	Debug	 			bool			`json:"debug"`
+	// Either `text` (default) for plain messages followed by their fields or `json` for one JSON 
+	// object per log line
+	LogFormat			string			`json:"log_format"`
 }
 
 func NewConfigurationFromJSONFile(file string) (err error, config Config) {
@@ -47,6 +82,30 @@ func NewConfigurationFromJSONFile(file string) (err error, config Config) {
     return
 }
 
+func NewDatabase(config DatabaseConfig) (db streambot.Database, err error) {
+	switch config.Driver {
+	case "", "rexster":
+		db, err = streambot.NewGraphDatabase(config.Graph, config.Hosts)
+	case "bolt":
+		if config.DataDir == "" {
+			err = errors.New("Missing data directory for database driver `bolt`")
+			return
+		}
+		db, err = streambot.NewBoltDatabase(config.DataDir)
+	case "sqlite3", "postgres":
+		if config.DSN == "" {
+			err = errors.New(fmt.Sprintf("Missing data source name for database driver `%s`", config.Driver))
+			return
+		}
+		db, err = streambot.NewSQLDatabase(config.Driver, config.DSN)
+	case "memory":
+		db = streambot.NewMemoryDatabase()
+	default:
+		err = errors.New(fmt.Sprintf("Unknown database driver `%s`", config.Driver))
+	}
+	return
+}
+
 func ReadConfig(file string) Config {
 	err, config := NewConfigurationFromJSONFile(file)
 	if err != nil {
@@ -57,10 +116,21 @@ func ReadConfig(file string) Config {
 }
 
 var config Config
+var checkCommand CheckCommand
+// Name of the subcommand to run instead of the API server
+var command string
 
 func init() {
 	var options Options
 	var parser = flags.NewParser(&options, flags.Default)
+	parser.SubcommandsOptional = true
+	_, err := parser.AddCommand("check", "Check database consistency", 
+		"Reports Channels stored with duplicate uids and dangling subscriptions of the graph " + 
+		"database, optionally merging the duplicates.", &checkCommand)
+	if err != nil {
+		fmt.Println(fmt.Sprintf("Error when adding command: %v", err))
+		os.Exit(1)
+	}
     if _, err := parser.Parse(); err != nil {
     	fmt.Println(fmt.Sprintf("Error when parsing arguments: %v", err))
         os.Exit(1)
@@ -69,12 +139,26 @@ func init() {
     	fmt.Println("Missing a valid configuration file specification argument. Usage: -c <config_file>")
     	os.Exit(1)	
     }
+    if parser.Active != nil {
+    	command = parser.Active.Name
+    }
     config = ReadConfig(options.ConfigFilepath)
 	// Customize the output format
-    logging.SetFormatter(logging.MustStringFormatter("%{message}"))
+	logFlags := stdlog.LstdFlags/*|stdlog.Lshortfile*/
+	switch config.LogFormat {
+	case "", "text":
+		logging.SetFormatter(logging.MustStringFormatter("%{message}"))
+	case "json":
+		// JSON lines carry their own timestamp and must not be prefixed or colored
+		logging.SetFormatter(streambot.JSONFormatter{})
+		logFlags = 0
+	default:
+		fmt.Println(fmt.Sprintf("Unknown log format `%s`", config.LogFormat))
+		os.Exit(1)
+	}
     // Setup one stdout and one syslog backend.
-    logBackend := logging.NewLogBackend(os.Stderr, "", stdlog.LstdFlags/*|stdlog.Lshortfile*/)
-    logBackend.Color = true
+    logBackend := logging.NewLogBackend(os.Stderr, "", logFlags)
+    logBackend.Color = config.LogFormat != "json"
     syslogBackend, err := logging.NewSyslogBackend("")
     if err != nil {
         log.Fatal(err)
@@ -88,15 +172,124 @@ func init() {
     }
 }
 
+// Sets up the configured metrics sinks. Metrics are dropped by a nil Statter if there is none, 
+// either because metrics are disabled or because StatsD cannot be reached. Metrics to be scraped 
+// by Prometheus are served by the admin handler if the admin server is configured.
+func NewStatter(config StatsConfig, admin *http.ServeMux) *streambot.Statter {
+	if config.Disabled {
+		log.Info("Metrics are disabled")
+		return nil
+	}
+	var sinks []streambot.MetricsSink
+	sink, err := streambot.NewStatsDSink(streambot.StatterOptions{
+		Host: config.Host,
+		Port: config.Port,
+		Prefix: config.Prefix,
+		SampleRate: config.SampleRate,
+		SampleRates: config.SampleRates,
+	})
+	if err != nil {
+		log.Error("Error when instantiate StatsD statter, continuing without StatsD: %v", err)
+	} else {
+		sinks = append(sinks, sink)
+	}
+	if config.AdminPort > 0 {
+		registry := streambot.NewPrometheusRegistry("streambot")
+		sinks = append(sinks, registry)
+		admin.Handle("/metrics", registry)
+	}
+	if config.SummaryInterval > 0 {
+		summaries := streambot.NewPercentileSink()
+		sinks = append(sinks, summaries)
+		go summaries.LogEvery(time.Duration(config.SummaryInterval) * time.Second)
+	}
+	if len(sinks) == 0 {
+		return nil
+	}
+	return streambot.NewStatter(sinks...)
+}
+
+// Reports inconsistencies of the database and repairs them if asked to. Returns the exit code, 
+// which is non-zero as long as inconsistencies remain.
+func CheckConsistency(db streambot.Database, repair bool) int {
+	checker, ok := db.(streambot.ConsistencyChecker)
+	if !ok {
+		log.Error("Database driver `%s` does not support consistency checks", config.Database.Driver)
+		return 1
+	}
+	err, report := checker.CheckConsistency()
+	if err != nil {
+		log.Error("Unexpected error when checking database consistency: %v", err)
+		return 1
+	}
+	for _, dup := range report.DuplicateUids {
+		log.Warning("Channel uid `%s` is stored on %d vertices %v", dup.Uid, len(dup.VertexIds), dup.VertexIds)
+	}
+	for _, sub := range report.DanglingSubscriptions {
+		log.Warning("Subscription edge `%s` from `%s` to `%s` is dangling", sub.EdgeId, sub.FromUid, sub.ToUid)
+	}
+	if report.Consistent() {
+		log.Info("Database is consistent")
+		return 0
+	}
+	log.Info("Found %d duplicate uids and %d dangling subscriptions", 
+		len(report.DuplicateUids), len(report.DanglingSubscriptions))
+	if !repair {
+		return 1
+	}
+	code := 0
+	for _, dup := range report.DuplicateUids {
+		err, survivorId := checker.MergeDuplicateUid(dup.Uid)
+		if err != nil {
+			log.Error("Unexpected error when merging Channel uid `%s`: %v", dup.Uid, err)
+			code = 1
+			continue
+		}
+		log.Info("Merged Channel uid `%s` into vertex `%s`", dup.Uid, survivorId)
+	}
+	// Dangling subscriptions are only reported, as there is no Channel to move them to
+	if len(report.DanglingSubscriptions) > 0 {
+		code = 1
+	}
+	return code
+}
+
 func main() {
-	db, err := streambot.NewGraphDatabase(
-		config.Database.Graph, 
-		config.Database.Hosts,
-	)
+	db, err := NewDatabase(config.Database)
 	if err != nil {
-		log.Fatalf("Unexpected error when intializing graph database driver: %v", err)
+		log.Fatalf("Unexpected error when intializing database driver: %v", err)
+	}
+	if command == "check" {
+		os.Exit(CheckConsistency(db, checkCommand.Repair))
+	}
+	admin := http.NewServeMux()
+	statter := NewStatter(config.Stats, admin)
+	var stats streambot.Stats = streambot.NoopStats{}
+	if statter != nil {
+		stats = statter
+	}
+	api := streambot.NewAPI(db, stats)
+	if config.Server.MaxSubscriptionDepth > 0 {
+		api.Channels.MaxSubscriptionDepth = config.Server.MaxSubscriptionDepth
+	}
+	// The search index lives in process memory only, so it starts out empty on every launch. It is 
+	// rebuilt in the background to serve right away, searches may miss Channels until then. Channels 
+	// written meanwhile are indexed as usual and survive the rebuild.
+	go func() {
+		if err := api.Channels.SearchIndex.Rebuild(db); err != nil {
+			log.Error("Unexpected error when rebuilding search index: %v", err)
+			return
+		}
+		log.Info("Rebuilt search index of %d Channels", api.Channels.SearchIndex.Len())
+	}()
+	if config.Stats.AdminPort > 0 {
+		admin.Handle("/search-index/rebuild", streambot.RebuildSearchIndexHandler(api.Channels.SearchIndex, db))
+		log.Info("Running admin server on Port %d", config.Stats.AdminPort)
+		go func() {
+			err := http.ListenAndServe(fmt.Sprintf(":%d", config.Stats.AdminPort), admin)
+			log.Error("Unexpected error occurred when serving admin server: %v", err)
+		}()
 	}
-	api := streambot.NewAPI(db)
 	errChan := make(chan error, 1)
 	basePath := "/v1/"
 	log.Info("Running API server on Port %d at base path %s", config.Server.Port, basePath)
@@ -119,6 +312,7 @@ func main() {
 	if err != nil {
 		log.Error("Unexpected error occurred when starting API: %v", err)
 	}
+	statter.Close()
 	log.Info("Finish.") 
 	os.Exit(0)   
 }
\ No newline at end of file
@@ -7,14 +7,18 @@ import (
   "time"
   "sync"
   "errors"
+  "strconv"
+  "strings"
   "github.com/laurent22/ripple"
 )
 
 type API struct { 
   App       ripple.Application
+  Channels  *ChannelController
   GoClose   chan bool
   Server    APIServer
   Closed    chan bool
+  Stats     Stats
 }
 
 func(api API) Serve(Port int, Route string, ErrorChannel chan error) {
@@ -39,7 +43,7 @@ func(api API) Serve(Port int, Route string, ErrorChannel chan error) {
     make(chan bool, 1),
   }
   handler := http.NewServeMux()
-  handler.HandleFunc(Route, api.App.ServeHTTP)
+  handler.HandleFunc(Route, WithRequestScope(Route, Instrument(api.Stats, Route, api.App.ServeHTTP)))
   api.Server.Handler = handler
   go func() {
     <- api.GoClose
@@ -52,27 +56,112 @@ func(api API) Serve(Port int, Route string, ErrorChannel chan error) {
       errMsg := fmt.Sprintf("An error occurred when launching API server: %v", err)
       ErrorChannel <- errors.New(errMsg)
     }
+    // Requests accepted before the listener closed may still record metrics or use the database
+    api.Server.WaitUnfinished()
     api.Closed <- true
   }()
 }
   
+// Keeps track of the status code and size of a response
+type responseRecorder struct {
+  http.ResponseWriter
+  Status  int
+  Size    int
+}
+
+func(r *responseRecorder) WriteHeader(status int) {
+  r.Status = status
+  r.ResponseWriter.WriteHeader(status)
+}
+
+func(r *responseRecorder) Write(b []byte) (n int, err error) {
+  n, err = r.ResponseWriter.Write(b)
+  r.Size += n
+  return
+}
+
+// Counts and times every request handled by the ripple application and measures its response, 
+// labelled by route and response status code. Every request is logged along with the fields of 
+// its RequestScope.
+func Instrument(stats Stats, basePath string, handler http.HandlerFunc) http.HandlerFunc {
+  return func(w http.ResponseWriter, r *http.Request) {
+    beforeRequest := time.Now()
+    recorder := &responseRecorder{w, http.StatusOK, 0}
+    handler(recorder, r)
+    duration := time.Since(beforeRequest)
+    route := Label{"route", RouteLabel(r, basePath)}
+    status := Label{"status", strconv.Itoa(recorder.Status)}
+    scope := RequestScopeFromRequest(r)
+    logger := scope.Logger.With(Fields{
+      "status": recorder.Status,
+      "duration_ms": float64(duration) / float64(time.Millisecond),
+      "size": recorder.Size,
+    })
+    if scope.Err != nil {
+      logger = logger.WithError(scope.Err)
+    }
+    logger.Info("Handled request %s %s", r.Method, r.URL.Path)
+    stats.Count("http.requests", route, status)
+    stats.Time("http.requests", duration, route, status)
+    stats.Size("http.responses", recorder.Size, route, status)
+  }
+}
+
+var routeMethods = map[string]bool{"GET": true, "PUT": true, "PATCH": true, "POST": true, "DELETE": true}
+var routeActions = map[string]bool{"subscriptions": true, "subscribers": true}
+
+// Route of the request like `GET /channels/:id/subscriptions` to label metrics with. Ids are left 
+// out and unknown paths and methods are summarized, so that the number of labels stays bounded.
+func RouteLabel(r *http.Request, basePath string) string {
+  if !routeMethods[r.Method] {
+    return "unmatched"
+  }
+  segments := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, basePath), "/"), "/")
+  if segments[0] != "channels" || len(segments) > 4 {
+    return "unmatched"
+  }
+  route := r.Method + " /channels"
+  if len(segments) == 2 && segments[1] == "search" {
+    return route + "/search"
+  }
+  if len(segments) > 1 {
+    route += "/:id"
+  }
+  if len(segments) > 2 {
+    if !routeActions[segments[2]] {
+      return "unmatched"
+    }
+    route += "/" + segments[2]
+  }
+  if len(segments) > 3 {
+    route += "/:target"
+  }
+  return route
+}
+
 func(api API) Shutdown() {
   api.GoClose <- true
 }
 
-func NewAPI(db Database) (api *API) {
+func NewAPI(db Database, statter Stats) (api *API) {
+  if statter == nil {
+    statter = NoopStats{}
+  }
   api = new(API)
   app := ripple.NewApplication()
-  statter, err := NewLocalStatsDStatter()
-  if err != nil {
-    log.Error("Error when instantiate StatsD statter: %v", err)
-  }
+  index := NewSearchIndex()
+  db = NewSearchIndexedDatabase(NewTimedDatabase(db, statter), index)
   channelController :=  NewChannelController(db, statter)
+  channelController.SearchIndex = index
   app.RegisterController("channels", channelController)
+  app.AddRoute(ripple.Route{ Pattern: ":_controller/search", Action: "search" })
+  app.AddRoute(ripple.Route{ Pattern: ":_controller/:id/:_action/:target" })
   app.AddRoute(ripple.Route{ Pattern: ":_controller/:id/:_action" })
   app.AddRoute(ripple.Route{ Pattern: ":_controller/:id/" })
   app.AddRoute(ripple.Route{ Pattern: ":_controller" })
   api.App = *app
+  api.Channels = channelController
+  api.Stats = statter
   api.GoClose = make(chan bool, 1)
   api.Closed = make(chan bool, 1)
 	return
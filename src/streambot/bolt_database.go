@@ -0,0 +1,333 @@
+package streambot
+
+import(
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+	"github.com/boltdb/bolt"
+)
+
+/* The BoltDatabase persists channels and their subscription edges in a single Bolt key/value 
+ * file, which makes it a fit for single-node deployments without a Titan+Cassandra cluster. 
+ * Channels are kept JSON encoded by uid. Every subscription edge is stored twice, once in a 
+ * bucket per subscribing channel and once in a bucket per subscribed channel, so both traversal 
+ * directions are plain bucket scans. */
+
+// Name of the database file within the configured data directory
+const BoltDatabaseFile = "streambot.db"
+
+var (
+	channelsBucket		= []byte("channels")
+	subscriptionsBucket	= []byte("subscriptions")
+	subscribersBucket	= []byte("subscribers")
+)
+
+type BoltDatabase struct {
+	DB *bolt.DB
+}
+
+func NewBoltDatabase(dataDir string) (db *BoltDatabase, err error) {
+	err = os.MkdirAll(dataDir, 0755)
+	if err != nil {
+		errMsgFormat := "Unexpected error when creating data directory `%s`: %v"
+		err = errors.New(fmt.Sprintf(errMsgFormat, dataDir, err))
+		return
+	}
+	path := filepath.Join(dataDir, BoltDatabaseFile)
+	b, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		errMsgFormat := "Unexpected error when opening database file `%s`"
+		err = NewBackendUnavailableError(fmt.Sprintf(errMsgFormat, path), err)
+		return
+	}
+	err = b.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{channelsBucket, subscriptionsBucket, subscribersBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Close()
+		errMsgFormat := "Unexpected error when creating buckets in database file `%s`: %v"
+		err = errors.New(fmt.Sprintf(errMsgFormat, path, err))
+		return
+	}
+	db = &BoltDatabase{b}
+	return
+}
+
+func (db *BoltDatabase) Close() error {
+	return db.DB.Close()
+}
+
+func (db *BoltDatabase) SaveChannel(ch *Channel) (err error) {
+	buf, err := json.Marshal(ch)
+	if err != nil {
+		err = errors.New(fmt.Sprintf("Unexpected error when encoding Channel `%v`: %v", ch, err))
+		return
+	}
+	err = db.DB.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(channelsBucket).Put([]byte(ch.Id), buf)
+	})
+	if err != nil {
+		err = errors.New(fmt.Sprintf("Unexpected error when saving Channel `%v`: %v", ch, err))
+	}
+	return
+}
+
+func (db *BoltDatabase) GetChannelWithUid(uid string) (err error, ch *Channel) {
+	err = db.DB.View(func(tx *bolt.Tx) (err error) {
+		err, ch = getBoltChannel(tx, uid)
+		return
+	})
+	return
+}
+
+func (db *BoltDatabase) UpdateChannel(uid string, version int64, patch ChannelPatch) (err error, ch *Channel) {
+	err = db.DB.Update(func(tx *bolt.Tx) (err error) {
+		err, ch = getBoltChannel(tx, uid)
+		if err != nil {
+			return
+		}
+		if !ch.HasVersion(version) {
+			return ErrVersionMismatch
+		}
+		patch.Apply(ch, time.Now().Unix())
+		buf, err := json.Marshal(ch)
+		if err != nil {
+			return
+		}
+		return tx.Bucket(channelsBucket).Put([]byte(uid), buf)
+	})
+	if err != nil {
+		ch = nil
+		if !errors.Is(err, ErrChannelNotFound) && !errors.Is(err, ErrVersionMismatch) {
+			errMsgFormat := "Unexpected error when updating Channel with Id `%s`: %v"
+			err = errors.New(fmt.Sprintf(errMsgFormat, uid, err))
+		}
+	}
+	return
+}
+
+func (db *BoltDatabase) ListChannels(
+	filter ChannelFilter, 
+	order ChannelSort, 
+	page Page,
+) (err error, chs []Channel) {
+	all := make([]Channel, 0)
+	err = db.DB.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(channelsBucket).ForEach(func(k, v []byte) error {
+			err, ch := decodeBoltChannel(string(k), v)
+			if err != nil {
+				return err
+			}
+			all = append(all, *ch)
+			return nil
+		})
+	})
+	if err != nil {
+		err = errors.New(fmt.Sprintf("Failed to list channels: %v", err))
+		return
+	}
+	chs = listChannels(all, filter, order, page)
+	return
+}
+
+func getBoltChannel(tx *bolt.Tx, uid string) (err error, ch *Channel) {
+	buf := tx.Bucket(channelsBucket).Get([]byte(uid))
+	if buf == nil {
+		err = ErrChannelNotFound
+		return
+	}
+	return decodeBoltChannel(uid, buf)
+}
+
+func decodeBoltChannel(uid string, buf []byte) (err error, ch *Channel) {
+	ch = new(Channel)
+	err = json.Unmarshal(buf, ch)
+	if err != nil {
+		err = errors.New(fmt.Sprintf("Unexpected error when decoding Channel `%s`: %v", uid, err))
+	} else if ch.Version == 0 {
+		// Channels stored before versioning lack the version
+		ch.Version = InitialChannelVersion
+	}
+	return
+}
+
+func (db *BoltDatabase) SaveChannelSubscription(
+	fromChannelId string, 
+	toChannelId string, 
+	creationTime int64,
+) (err error) {
+	err = db.DB.Update(func(tx *bolt.Tx) error {
+		channels := tx.Bucket(channelsBucket)
+		for _, uid := range []string{fromChannelId, toChannelId} {
+			if channels.Get([]byte(uid)) == nil {
+				errMsgFormat := "Unexpected error when saving Channel Subscription, unknown Channel `%s`"
+				return WrapError(ErrChannelNotFound, fmt.Sprintf(errMsgFormat, uid))
+			}
+		}
+		if getBoltEdge(tx, subscriptionsBucket, fromChannelId, toChannelId) != nil {
+			return nil
+		}
+		value := make([]byte, 8)
+		binary.BigEndian.PutUint64(value, uint64(creationTime))
+		if err := putBoltEdge(tx, subscriptionsBucket, fromChannelId, toChannelId, value); err != nil {
+			return err
+		}
+		return putBoltEdge(tx, subscribersBucket, toChannelId, fromChannelId, value)
+	})
+	return
+}
+
+func (db *BoltDatabase) DeleteChannelSubscription(fromChannelId string, toChannelId string) (err error) {
+	err = db.DB.Update(func(tx *bolt.Tx) error {
+		if err := deleteBoltEdge(tx, subscriptionsBucket, fromChannelId, toChannelId); err != nil {
+			return err
+		}
+		return deleteBoltEdge(tx, subscribersBucket, toChannelId, fromChannelId)
+	})
+	if err != nil {
+		errMsgFormat := "Unexpected error when deleting Channel Subscription: %v"
+		err = errors.New(fmt.Sprintf(errMsgFormat, err))
+	}
+	return
+}
+
+func (db *BoltDatabase) GetSubscriptionsForChannelWithUid(
+	uid string, 
+	depth int,
+	page Page,
+) (err error, subs []Subscription) {
+	err = db.DB.View(func(tx *bolt.Tx) error {
+		edgesFrom := func(from string) (err error, edges map[string]int64) {
+			edges = make(map[string]int64)
+			bucket := tx.Bucket(subscriptionsBucket).Bucket([]byte(from))
+			if bucket == nil {
+				return
+			}
+			err = bucket.ForEach(func(k, v []byte) error {
+				edges[string(k)] = int64(binary.BigEndian.Uint64(v))
+				return nil
+			})
+			return
+		}
+		channelWithUid := func(uid string) (err error, ch Channel) {
+			err, stored := getBoltChannel(tx, uid)
+			if err != nil {
+				return
+			}
+			return nil, *stored
+		}
+		err, all := walkSubscriptions(uid, depth, edgesFrom, channelWithUid)
+		if err != nil {
+			return err
+		}
+		subs = pageOfSubscriptions(all, page)
+		return nil
+	})
+	if err != nil {
+		err = errors.New(fmt.Sprintf("Failed to query subscribed channels: %v", err))
+	}
+	return
+}
+
+func (db *BoltDatabase) GetSubscribersForChannelWithUid(uid string) (err error, chs []Channel) {
+	chs = make([]Channel, 0)
+	err = db.DB.View(func(tx *bolt.Tx) error {
+		edges := tx.Bucket(subscribersBucket).Bucket([]byte(uid))
+		if edges == nil {
+			return nil
+		}
+		return edges.ForEach(func(k, v []byte) error {
+			err, ch := getBoltChannel(tx, string(k))
+			if err != nil {
+				return err
+			}
+			chs = append(chs, *ch)
+			return nil
+		})
+	})
+	if err != nil {
+		err = errors.New(fmt.Sprintf("Failed to query subscribing channels: %v", err))
+	}
+	return
+}
+
+func (db *BoltDatabase) DeleteChannel(uid string, version int64) (err error) {
+	err = db.DB.Update(func(tx *bolt.Tx) error {
+		channels := tx.Bucket(channelsBucket)
+		err, ch := getBoltChannel(tx, uid)
+		if err != nil {
+			return err
+		}
+		if !ch.HasVersion(version) {
+			return ErrVersionMismatch
+		}
+		for _, direction := range [][2][]byte{
+			{subscriptionsBucket, subscribersBucket}, 
+			{subscribersBucket, subscriptionsBucket},
+		} {
+			edges := tx.Bucket(direction[0]).Bucket([]byte(uid))
+			if edges == nil {
+				continue
+			}
+			var others []string
+			edges.ForEach(func(k, v []byte) error {
+				others = append(others, string(k))
+				return nil
+			})
+			for _, other := range others {
+				if err := deleteBoltEdge(tx, direction[1], other, uid); err != nil {
+					return err
+				}
+			}
+			if err := tx.Bucket(direction[0]).DeleteBucket([]byte(uid)); err != nil {
+				return err
+			}
+		}
+		return channels.Delete([]byte(uid))
+	})
+	if err != nil && !errors.Is(err, ErrChannelNotFound) && !errors.Is(err, ErrVersionMismatch) {
+		errMsgFormat := "Unexpected error when deleting Channel with Id `%s`: %v"
+		err = errors.New(fmt.Sprintf(errMsgFormat, uid, err))
+	}
+	return
+}
+
+func getBoltEdge(tx *bolt.Tx, bucket []byte, from string, to string) []byte {
+	edges := tx.Bucket(bucket).Bucket([]byte(from))
+	if edges == nil {
+		return nil
+	}
+	return edges.Get([]byte(to))
+}
+
+func putBoltEdge(tx *bolt.Tx, bucket []byte, from string, to string, value []byte) error {
+	edges, err := tx.Bucket(bucket).CreateBucketIfNotExists([]byte(from))
+	if err != nil {
+		return err
+	}
+	return edges.Put([]byte(to), value)
+}
+
+func deleteBoltEdge(tx *bolt.Tx, bucket []byte, from string, to string) error {
+	edges := tx.Bucket(bucket).Bucket([]byte(from))
+	if edges == nil {
+		return nil
+	}
+	if err := edges.Delete([]byte(to)); err != nil {
+		return err
+	}
+	// Drop the bucket of a channel once it has no edges left in this direction
+	if k, _ := edges.Cursor().First(); k == nil {
+		return tx.Bucket(bucket).DeleteBucket([]byte(from))
+	}
+	return nil
+}
\ No newline at end of file
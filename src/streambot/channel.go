@@ -2,15 +2,65 @@ package streambot
 
 import(
 	"code.google.com/p/go-uuid/uuid"
+	"time"
 )
 
+// A Channel with its metadata. CreatedAt and UpdatedAt are Unix times, they are zero for 
+// Channels stored before these were tracked, just like the other optional fields are empty. 
+// Version starts at 1 and is incremented on every update of the Channel.
 type Channel struct {
 	Id			string
 	Name		string
+	Description	string
+	OwnerId		string
+	CreatedAt	int64
+	UpdatedAt	int64
+	Version		int64
 }
 
+// Version of new Channels, which Channels stored before they were versioned are read at as well, 
+// so that their entity tags can be used in conditional requests
+const InitialChannelVersion int64 = 1
+
 func NewChannel(name string) (ch *Channel) { 
 	// Create a new runtime Channel object
-	ch = &Channel{uuid.New(), name}
+	now := time.Now().Unix()
+	ch = &Channel{Id: uuid.New(), Name: name, CreatedAt: now, UpdatedAt: now, Version: InitialChannelVersion}
 	return
+}
+
+// Whether the Channel is at the given version, where version 0 matches any version
+func (ch *Channel) HasVersion(version int64) bool {
+	return version == 0 || ch.Version == version
+}
+
+// Changes to the mutable fields of a Channel, fields left nil are kept as they are
+type ChannelPatch struct {
+	Name		*string
+	Description	*string
+	OwnerId		*string
+}
+
+// Applies the changes to the Channel and marks it updated at the given Unix time with the next 
+// version
+func (patch ChannelPatch) Apply(ch *Channel, updatedAt int64) {
+	if patch.Name != nil {
+		ch.Name = *patch.Name
+	}
+	if patch.Description != nil {
+		ch.Description = *patch.Description
+	}
+	if patch.OwnerId != nil {
+		ch.OwnerId = *patch.OwnerId
+	}
+	ch.UpdatedAt = updatedAt
+	ch.Version++
+}
+
+// A subscription of a Channel to another Channel, created at given Unix time. Hops is the 
+// distance to the subscribed Channel, 1 for direct subscriptions.
+type Subscription struct {
+	Channel		Channel
+	CreatedAt	int64
+	Hops		int
 }
\ No newline at end of file
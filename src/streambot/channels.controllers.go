@@ -5,18 +5,82 @@ import(
   "encoding/json"
   "github.com/laurent22/ripple"
   "github.com/op/go-logging"
+  "net/http"
   "time"
+  "strconv"
+  "errors"
+  "fmt"
+  "sort"
 )
 
 var log = logging.MustGetLogger("streambot-api")
 
+// Depth of subscription traversals clients may ask for, unless configured otherwise
+const DefaultMaxSubscriptionDepth = 5
+
 type ChannelController struct {
-  Database  Database
-  Stats     *Statter
+  Database              Database
+  Stats                 Stats
+  MaxSubscriptionDepth  int
+  SearchIndex           *SearchIndex
+}
+
+func NewChannelController(db Database, stats Stats) *ChannelController {
+  if stats == nil {
+    stats = NoopStats{}
+  }
+  return &ChannelController{db, stats, DefaultMaxSubscriptionDepth, NewSearchIndex()}
+}
+
+// Logger and Database scoped to the request, so that their log lines can be correlated
+func(ctrl *ChannelController) scope(ctx *ripple.Context) (logger *Logger, db Database) {
+  scope := RequestScopeFromRequest(ctx.Request)
+  if id := ctx.Params["id"]; id != "" {
+    scope.Logger = scope.Logger.With(Fields{"channel_id": id})
+  }
+  return scope.Logger, DatabaseWithLogger(ctrl.Database, scope.Logger)
+}
+
+type ErrorOutData struct {
+  Error ErrorDetailOutData `json:"error"`
 }
 
-func NewChannelController(db Database, stats *Statter) *ChannelController {
-  return &ChannelController{db, stats}
+type ErrorDetailOutData struct {
+  Code      ErrorCode     `json:"code"`
+  Message   string        `json:"message"`
+  RequestId string        `json:"request_id"`
+  Details   []FieldError  `json:"details,omitempty"`
+}
+
+// Id of the request as given by the client in the `X-Request-Id` header or a newly generated one
+func RequestIdFromRequest(r *http.Request) string {
+  return RequestScopeFromRequest(r).Id
+}
+
+// Responds the JSON error envelope for err with the HTTP status code matching its ErrorCode. The 
+// messages of internal errors are not exposed to clients, as they may tell about the backend.
+func RespondWithError(ctx *ripple.Context, err error) {
+  code := ErrorCodeOf(err)
+  message := "Internal server error"
+  var details []FieldError
+  var e *Error
+  if code != ErrorCodeInternal && errors.As(err, &e) {
+    message = e.Message
+    details = e.Details
+  }
+  scope := RequestScopeFromRequest(ctx.Request)
+  scope.Err = err
+  requestId := scope.Id
+  SetResponseHeader(ctx, "X-Request-Id", requestId)
+  ctx.Response.Status = code.HTTPStatus()
+  ctx.Response.Body = ErrorOutData{ErrorDetailOutData{code, message, requestId, details}}
+}
+
+func SetResponseHeader(ctx *ripple.Context, name string, value string) {
+  if ctx.Response.Header == nil {
+    ctx.Response.Header = http.Header{}
+  }
+  ctx.Response.Header.Set(name, value)
 }
 
 type PutChannelOutData struct {
@@ -24,80 +88,291 @@ type PutChannelOutData struct {
 }
 
 type PutChannelInData struct {
-  Name string `json:"name"`
+  Name        string `json:"name"`
+  Description string `json:"description"`
+  OwnerId     string `json:"owner_id"`
 }
 
 func(ctrl *ChannelController) Put(ctx *ripple.Context) {
+  logger, db := ctrl.scope(ctx)
   ctrl.Stats.Count("channels.put")
   // Read the request into a raw buffer and unmarshal buffer to further handle request
   body, err := ioutil.ReadAll(ctx.Request.Body)
   if err != nil {
-    ctx.Response.Status = 501
+    RespondWithError(ctx, NewError(ErrorCodeInvalidArgument, "Failed to read request body", err))
     errMsgFormat := "Unexpected error when read request body of Channel PUT: %v"
-    log.Error(errMsgFormat, err)
+    logger.Error(errMsgFormat, err)
     return
   }
   var req PutChannelInData
   err = json.Unmarshal(body, &req)
   if err != nil {
-    ctx.Response.Status = 400
+    RespondWithError(ctx, NewError(ErrorCodeInvalidArgument, "Request body is not valid JSON", err))
     errMsgFormat := "Unexpected error when parse Channel PUT request body `%v` at Rexster " +
     "backend: %v"
-    log.Error(errMsgFormat, string(body), err)
+    logger.Error(errMsgFormat, string(body), err)
+    return
+  }
+  if errs := req.Validate(); len(errs) > 0 {
+    RespondWithError(ctx, NewValidationError(errs))
+    logger.Error("Invalid Channel PUT request: %v", errs)
     return
   }
   ch := NewChannel(req.Name)
-  // Track timestamps in nanosecond precision before and after the database call
-  beforeDB := time.Now()
-  err = ctrl.Database.SaveChannel(ch)
-  afterDB := time.Now()
-  // Calculate database call duration and track in statter
-  duration := afterDB.Sub(beforeDB)/time.Millisecond
-  log.Debug("Database call SaveChannel in channels.Put took %d", duration)
-  if err == nil {
-    ctrl.Stats.Time("db.SaveChannel", int(duration))
-  } else {
-    // Evaluate errors
-    ctx.Response.Status = 501
-    log.Error("Database controller returned unexpected error on save Channel `%v`: %v", ch, err)
+  ch.Description = req.Description
+  ch.OwnerId = req.OwnerId
+  err = db.SaveChannel(ch)
+  if err != nil {
+    RespondWithError(ctx, err)
+    logger.Error("Database controller returned unexpected error on save Channel `%v`: %v", ch, err)
     return
   }
+  SetResponseHeader(ctx, "ETag", ChannelETag(ch))
   ctx.Response.Body = PutChannelOutData{ch.Id}
 }
 
 type GetChannelOutData struct {
-  Id    string `json:"id"`
-  Name  string `json:"name"`
+  Id          string `json:"id"`
+  Name        string `json:"name"`
+  Description string `json:"description"`
+  OwnerId     string `json:"owner_id"`
+  CreatedAt   int64  `json:"created_at"`
+  UpdatedAt   int64  `json:"updated_at"`
+  Version     int64  `json:"version"`
+}
+
+func NewGetChannelOutData(ch Channel) GetChannelOutData {
+  return GetChannelOutData{
+    ch.Id, ch.Name, ch.Description, ch.OwnerId, ch.CreatedAt, ch.UpdatedAt, ch.Version,
+  }
+}
+
+type ListChannelsOutData struct {
+  Channels    []GetChannelOutData `json:"channels"`
+  NextCursor  string              `json:"next_cursor,omitempty"`
 }
 
 func(ctrl *ChannelController) Get(ctx *ripple.Context) {
-  ctrl.Stats.Count("channels.get")
+  logger, db := ctrl.scope(ctx)
   id := ctx.Params["id"]
   if id == "" {
-    ctx.Response.Status = 501
-    log.Error("Missing Id on Channel GET")
+    // Without an Id the Channels themselves are requested
+    ctrl.list(ctx)
     return
   }
-  // Track timestamps in nanosecond precision before and after the database call
-  beforeDB := time.Now()
-  err, ch := ctrl.Database.GetChannelWithUid(id)
-  afterDB := time.Now()
-  // Calculate database call duration and track in statter
-  duration := afterDB.Sub(beforeDB)/time.Millisecond
-  log.Debug("Database call GetChannelWithUid in channels.Get took %d", duration)
-  ctrl.Stats.Time("db.GetChannelWithUid", int(duration))
+  ctrl.Stats.Count("channels.get")
+  err, ch := db.GetChannelWithUid(id)
   if err != nil {
-    ctx.Response.Status = 501
-    log.Error("Unexpected error when fetch Channel with Id `%s` at Rexster backend: %v", id, err)
+    RespondWithError(ctx, err)
+    logger.Error("Unexpected error when fetch Channel with Id `%s` at Rexster backend: %v", id, err)
     return
   }
   if ch == nil {
-    ctx.Response.Status = 400
+    RespondWithError(ctx, ErrChannelNotFound)
     errMsgFormat := "Unexpected empty Channel when fetch Channel with Id `%s` at Rexster backend"
-    log.Error(errMsgFormat, id)
+    logger.Error(errMsgFormat, id)
     return
   }
-  ctx.Response.Body = GetChannelOutData{ch.Id, ch.Name}
+  etag := ChannelETag(ch)
+  SetResponseHeader(ctx, "ETag", etag)
+  // The client's representation is still current, so there is no need to send it again
+  if IfNoneMatch(ctx.Request, etag) {
+    ctx.Response.Status = http.StatusNotModified
+    return
+  }
+  ctx.Response.Body = NewGetChannelOutData(*ch)
+}
+
+func(ctrl *ChannelController) list(ctx *ripple.Context) {
+  logger, db := ctrl.scope(ctx)
+  ctrl.Stats.Count("channels.list")
+  query := ctx.Request.URL.Query()
+  filter := ChannelFilter{query.Get("name_prefix")}
+  order, err := ParseChannelSort(query.Get("sort"))
+  if err != nil {
+    RespondWithError(ctx, NewError(ErrorCodeInvalidArgument, err.Error(), nil))
+    logger.Error("Invalid sort order when list Channels: %v", err)
+    return
+  }
+  page, err := PageFromRequest(ctx.Request)
+  if err != nil {
+    RespondWithError(ctx, NewError(ErrorCodeInvalidArgument, err.Error(), nil))
+    logger.Error("Invalid page when list Channels: %v", err)
+    return
+  }
+  var chs []Channel
+  err, keep, nextCursor := FetchPage(page, func(page Page) (err error, fetched int) {
+    err, chs = db.ListChannels(filter, order, page)
+    return err, len(chs)
+  })
+  if err != nil {
+    RespondWithError(ctx, err)
+    logger.Error("Unexpected error when list Channels: %v", err)
+    return
+  }
+  chs = chs[:keep]
+  out := ListChannelsOutData{NextCursor: nextCursor}
+  out.Channels = make([]GetChannelOutData, len(chs))
+  for i := range chs {
+    out.Channels[i] = NewGetChannelOutData(chs[i])
+  }
+  ctx.Response.Body = out
+}
+
+type SearchChannelOutData struct {
+  GetChannelOutData
+  Score float64 `json:"score"`
+}
+
+type SearchChannelsOutData struct {
+  Channels    []SearchChannelOutData  `json:"channels"`
+  NextCursor  string                  `json:"next_cursor,omitempty"`
+}
+
+func(ctrl *ChannelController) GetSearch(ctx *ripple.Context) {
+  logger, _ := ctrl.scope(ctx)
+  ctrl.Stats.Count("channels.search")
+  q := ctx.Request.URL.Query().Get("q")
+  if len(Tokenize(q)) == 0 {
+    err := NewError(ErrorCodeInvalidArgument, "Query parameter `q` must contain a word", nil)
+    RespondWithError(ctx, err)
+    logger.Error("Invalid search query `%s`", q)
+    return
+  }
+  page, err := PageFromRequest(ctx.Request)
+  if err != nil {
+    RespondWithError(ctx, NewError(ErrorCodeInvalidArgument, err.Error(), nil))
+    logger.Error("Invalid page when search Channels: %v", err)
+    return
+  }
+  var results []SearchResult
+  _, keep, nextCursor := FetchPage(page, func(page Page) (err error, fetched int) {
+    results = ctrl.SearchIndex.Search(q, page)
+    return nil, len(results)
+  })
+  results = results[:keep]
+  out := SearchChannelsOutData{NextCursor: nextCursor}
+  out.Channels = make([]SearchChannelOutData, len(results))
+  for i, result := range results {
+    out.Channels[i] = SearchChannelOutData{NewGetChannelOutData(result.Channel), result.Score}
+  }
+  ctx.Response.Body = out
+}
+
+// Reads a JSON merge patch (RFC 7396) of a Channel. Optional fields are cleared by null, fields 
+// that cannot be changed or are unknown are reported as invalid.
+func ChannelPatchFromJSON(body []byte) (patch ChannelPatch, errs []FieldError, err error) {
+  var fields map[string]json.RawMessage
+  err = json.Unmarshal(body, &fields)
+  if err != nil {
+    return
+  }
+  if fields == nil {
+    err = errors.New("Merge patch must be a JSON object")
+    return
+  }
+  keys := make([]string, 0, len(fields))
+  for key := range fields {
+    keys = append(keys, key)
+  }
+  sort.Strings(keys)
+  for _, key := range keys {
+    var value *string
+    switch key {
+    case "name", "description", "owner_id":
+      if json.Unmarshal(fields[key], &value) != nil {
+        errs = append(errs, FieldError{key, "must be a string or null"})
+        continue
+      }
+      if value == nil {
+        value = new(string)
+      }
+    case "id", "created_at", "updated_at":
+      errs = append(errs, FieldError{key, "cannot be changed"})
+      continue
+    default:
+      errs = append(errs, FieldError{key, "is not a known field"})
+      continue
+    }
+    switch key {
+    case "name":
+      patch.Name = value
+    case "description":
+      patch.Description = value
+    case "owner_id":
+      patch.OwnerId = value
+    }
+  }
+  return
+}
+
+func(ctrl *ChannelController) Patch(ctx *ripple.Context) {
+  logger, db := ctrl.scope(ctx)
+  ctrl.Stats.Count("channels.patch")
+  id := ctx.Params["id"]
+  if id == "" {
+    RespondWithError(ctx, NewError(ErrorCodeInvalidArgument, "Missing Channel Id", nil))
+    logger.Error("Missing Id on Channel PATCH")
+    return
+  }
+  body, err := ioutil.ReadAll(ctx.Request.Body)
+  if err != nil {
+    RespondWithError(ctx, NewError(ErrorCodeInvalidArgument, "Failed to read request body", err))
+    logger.Error("Unexpected error when read request body of Channel PATCH with Id `%s`: %v", id, err)
+    return
+  }
+  patch, errs, err := ChannelPatchFromJSON(body)
+  if err != nil {
+    RespondWithError(ctx, NewError(ErrorCodeInvalidArgument, "Request body is not a JSON merge patch", err))
+    errMsgFormat := "Unexpected error when parse request body `%s` of Channel PATCH with Id `%s`: %v"
+    logger.Error(errMsgFormat, string(body), id, err)
+    return
+  }
+  if errs = append(errs, patch.Validate()...); len(errs) > 0 {
+    RespondWithError(ctx, NewValidationError(errs))
+    logger.Error("Invalid Channel PATCH request with Id `%s`: %v", id, errs)
+    return
+  }
+  version, err := VersionFromIfMatch(ctx.Request)
+  if err != nil {
+    RespondWithError(ctx, WrapError(ErrVersionMismatch, err.Error()))
+    logger.Error("Precondition failed on Channel PATCH with Id `%s`: %v", id, err)
+    return
+  }
+  err, ch := db.UpdateChannel(id, version, patch)
+  if err != nil {
+    RespondWithError(ctx, err)
+    logger.Error("Database controller returned unexpected error on update Channel with Id `%s`: %v", id, err)
+    return
+  }
+  SetResponseHeader(ctx, "ETag", ChannelETag(ch))
+  ctx.Response.Body = NewGetChannelOutData(*ch)
+}
+
+func(ctrl *ChannelController) Delete(ctx *ripple.Context) {
+  logger, db := ctrl.scope(ctx)
+  ctrl.Stats.Count("channels.delete")
+  id := ctx.Params["id"]
+  if id == "" {
+    RespondWithError(ctx, NewError(ErrorCodeInvalidArgument, "Missing Channel Id", nil))
+    logger.Error("Missing Id on Channel DELETE")
+    return
+  }
+  version, err := VersionFromIfMatch(ctx.Request)
+  if err != nil {
+    RespondWithError(ctx, WrapError(ErrVersionMismatch, err.Error()))
+    logger.Error("Precondition failed on Channel DELETE with Id `%s`: %v", id, err)
+    return
+  }
+  err = db.DeleteChannel(id, version)
+  if err != nil {
+    RespondWithError(ctx, err)
+    errMsgFormat := "Database controller returned unexpected error on delete Channel with Id " +
+    "`%s`: %v"
+    logger.Error(errMsgFormat, id, err)
+    return
+  }
+  ctx.Response.Status = 200
 }
 
 type PostChannelSubscriptionsInData struct {
@@ -106,80 +381,196 @@ type PostChannelSubscriptionsInData struct {
 }
 
 func(ctrl *ChannelController) PostSubscriptions(ctx *ripple.Context) {
+  logger, db := ctrl.scope(ctx)
   ctrl.Stats.Count("channels.subscriptions.post")
   fromChannelId := ctx.Params["id"]
   if fromChannelId == "" {
-    ctx.Response.Status = 400
-    log.Error("Missing Id on Channel POST")
+    RespondWithError(ctx, NewError(ErrorCodeInvalidArgument, "Missing Channel Id", nil))
+    logger.Error("Missing Id on Channel POST")
     return
   }
   // Read the request into a raw buffer and unmarshal buffer to post handle request
   body, err := ioutil.ReadAll(ctx.Request.Body)
   if err != nil {
-    ctx.Response.Status = 400
+    RespondWithError(ctx, NewError(ErrorCodeInvalidArgument, "Failed to read request body", err))
     errMsgFormat := "Unexpected error when read request body of Channel POST with Id `%s`: %v"
-    log.Error(errMsgFormat, fromChannelId, err)
+    logger.Error(errMsgFormat, fromChannelId, err)
     return
   }
   var req PostChannelSubscriptionsInData
   err = json.Unmarshal(body, &req)
   if err != nil {
-    ctx.Response.Status = 400
+    RespondWithError(ctx, NewError(ErrorCodeInvalidArgument, "Request body is not valid JSON", err))
     errMsgFormat := "Unexpected error when parse request body `%s` of Channel POST with Id `%s`: %v"
-    log.Error(errMsgFormat, string(body), fromChannelId, err)
+    logger.Error(errMsgFormat, string(body), fromChannelId, err)
+    return
+  }
+  if errs := req.Validate(fromChannelId); len(errs) > 0 {
+    RespondWithError(ctx, NewValidationError(errs))
+    logger.Error("Invalid Channel POST request with Id `%s`: %v", fromChannelId, errs)
+    return
+  }
+  // A subscription to an unknown Channel is a flaw of the request, not a missing resource
+  err, _ = db.GetChannelWithUid(req.ToChannelId)
+  if errors.Is(err, ErrChannelNotFound) {
+    RespondWithError(ctx, NewValidationError([]FieldError{{"channel_id", "must be an existing Channel"}}))
+    logger.Error("Cannot subscribe Channel with Id `%s` to unknown Channel `%s`", fromChannelId, req.ToChannelId)
+    return
+  }
+  if err != nil {
+    RespondWithError(ctx, err)
+    errMsgFormat := "Unexpected error when fetch subscribed Channel with Id `%s`: %v"
+    logger.Error(errMsgFormat, req.ToChannelId, err)
     return
   }
-  // Track timestamps in nanosecond precision before and after the database call
-  beforeDB := time.Now()
-  err = ctrl.Database.SaveChannelSubscription(fromChannelId, req.ToChannelId, req.Time)
-  afterDB := time.Now()
-  // Calculate database call duration and track in statter
-  duration := afterDB.Sub(beforeDB)/time.Millisecond
-  log.Debug("Database call SaveChannelSubscription in channels.PostSubscriptions took %d", duration)
-  ctrl.Stats.Time("db.SaveChannelSubscription", int(duration))
+  // Default to the server time when the client omitted the subscription's creation time
+  if req.Time == 0 {
+    req.Time = time.Now().Unix()
+  }
+  err = db.SaveChannelSubscription(fromChannelId, req.ToChannelId, req.Time)
   if err != nil {
-    ctx.Response.Status = 501
+    RespondWithError(ctx, err)
     errMsgFormat := "Database controller returned unexpected error on save subscription from " +
     "Channel with Id `%s` to Channel with Id `%s`, happend on `%d`: %v"
-    log.Error(errMsgFormat, fromChannelId, req.ToChannelId, req.Time, err)
+    logger.Error(errMsgFormat, fromChannelId, req.ToChannelId, req.Time, err)
     return
   }
   ctx.Response.Status = 200
 }
 
+func(ctrl *ChannelController) DeleteSubscriptions(ctx *ripple.Context) {
+  logger, db := ctrl.scope(ctx)
+  ctrl.Stats.Count("channels.subscriptions.delete")
+  fromChannelId := ctx.Params["id"]
+  toChannelId := ctx.Params["target"]
+  if fromChannelId == "" || toChannelId == "" {
+    RespondWithError(ctx, NewError(ErrorCodeInvalidArgument, "Missing Channel Id or target Id", nil))
+    logger.Error("Missing Id or target Id on Channel subscription DELETE")
+    return
+  }
+  err := db.DeleteChannelSubscription(fromChannelId, toChannelId)
+  if err != nil {
+    RespondWithError(ctx, err)
+    errMsgFormat := "Database controller returned unexpected error on delete subscription from " +
+    "Channel with Id `%s` to Channel with Id `%s`: %v"
+    logger.Error(errMsgFormat, fromChannelId, toChannelId, err)
+    return
+  }
+  ctx.Response.Status = 200
+}
+
+type GetChannelSubscriptionOutData struct {
+  Channel   GetChannelOutData `json:"channel"`
+  CreatedAt int64             `json:"created_at"`
+  Hops      int               `json:"hops"`
+}
+
+type GetChannelSubscriptionsOutData struct {
+  Subscriptions []GetChannelSubscriptionOutData `json:"subscriptions"`
+  NextCursor    string                          `json:"next_cursor,omitempty"`
+}
+
+// Reads the page of a listing from the `limit` and `cursor` query parameters of a request
+func PageFromRequest(r *http.Request) (page Page, err error) {
+  page.Limit = DefaultPageLimit
+  query := r.URL.Query()
+  if param := query.Get("limit"); param != "" {
+    page.Limit, err = strconv.Atoi(param)
+    if err != nil || page.Limit < 1 || page.Limit > MaxPageLimit {
+      err = errors.New(fmt.Sprintf("Limit `%s` is not within 1 and %d", param, MaxPageLimit))
+      return
+    }
+  }
+  if param := query.Get("cursor"); param != "" {
+    page.Offset, err = DecodeCursor(param)
+  }
+  return
+}
+
 func(ctrl *ChannelController) GetSubscriptions(ctx *ripple.Context) {
+  logger, db := ctrl.scope(ctx)
   ctrl.Stats.Count("channels.subscriptions.get")
   id := ctx.Params["id"]
   if id == "" {
-    ctx.Response.Status = 501
-    log.Error("Missing Channel Id when fetch subscriptions")
+    RespondWithError(ctx, NewError(ErrorCodeInvalidArgument, "Missing Channel Id", nil))
+    logger.Error("Missing Channel Id when fetch subscriptions")
     return
   }
-  // Track timestamps in nanosecond precision before and after the database call
-  beforeDB := time.Now()
-  err, chs := ctrl.Database.GetSubscriptionsForChannelWithUid(id)
-  afterDB := time.Now()
-  // Calculate database call duration and track in statter
-  duration := afterDB.Sub(beforeDB)/time.Millisecond
-  log.Debug("Database call GetSubscriptionsForChannelWithUid in channels.GetSubscriptions took %d", duration)
-  ctrl.Stats.Time("db.GetSubscriptionsForChannelWithUid", int(duration))
+  // Only direct subscriptions are returned unless a deeper traversal is asked for
+  depth := 1
+  if param := ctx.Request.URL.Query().Get("depth"); param != "" {
+    var err error
+    depth, err = strconv.Atoi(param)
+    if err != nil || depth < 1 {
+      errMsg := fmt.Sprintf("Depth `%s` is not a positive number", param)
+      RespondWithError(ctx, NewError(ErrorCodeInvalidArgument, errMsg, err))
+      logger.Error("Invalid depth `%s` when fetch subscriptions for Channel with Id `%s`", param, id)
+      return
+    }
+    if depth > ctrl.MaxSubscriptionDepth {
+      logger.Debug("Limit subscriptions depth %d to configured maximum %d", depth, ctrl.MaxSubscriptionDepth)
+      depth = ctrl.MaxSubscriptionDepth
+    }
+  }
+  page, err := PageFromRequest(ctx.Request)
   if err != nil {
-    ctx.Response.Status = 501
+    RespondWithError(ctx, NewError(ErrorCodeInvalidArgument, err.Error(), nil))
+    logger.Error("Invalid page when fetch subscriptions for Channel with Id `%s`: %v", id, err)
+    return
+  }
+  var subs []Subscription
+  err, keep, nextCursor := FetchPage(page, func(page Page) (err error, fetched int) {
+    err, subs = db.GetSubscriptionsForChannelWithUid(id, depth, page)
+    return err, len(subs)
+  })
+  if err != nil {
+    RespondWithError(ctx, err)
     errMsgFormat := "Unexpected error when fetch Channel subscriptions for Channel with Id `%s` " +
     "at Rexster backend: %v"
-    log.Error(errMsgFormat, id, err)
+    logger.Error(errMsgFormat, id, err)
     return
   }
-  if chs == nil {
-    ctx.Response.Status = 400
+  if subs == nil {
+    RespondWithError(ctx, errors.New("Database returned no subscriptions list"))
     errMsgFormat := "Unexpected empty Channels list when fetch Channel subscriptions for Channel" +
     " with Id `%s` at Rexster backend"
-    log.Error(errMsgFormat, id)
+    logger.Error(errMsgFormat, id)
+    return
+  }
+  subs = subs[:keep]
+  out := GetChannelSubscriptionsOutData{NextCursor: nextCursor}
+  outSubs := make([]GetChannelSubscriptionOutData, len(subs))
+  for i := range subs {
+    outSubs[i] = GetChannelSubscriptionOutData{
+      NewGetChannelOutData(subs[i].Channel), 
+      subs[i].CreatedAt, 
+      subs[i].Hops,
+    }
+  }
+  out.Subscriptions = outSubs
+  ctx.Response.Body = out
+}
+
+func(ctrl *ChannelController) GetSubscribers(ctx *ripple.Context) {
+  logger, db := ctrl.scope(ctx)
+  ctrl.Stats.Count("channels.subscribers.get")
+  id := ctx.Params["id"]
+  if id == "" {
+    RespondWithError(ctx, NewError(ErrorCodeInvalidArgument, "Missing Channel Id", nil))
+    logger.Error("Missing Channel Id when fetch subscribers")
+    return
+  }
+  err, chs := db.GetSubscribersForChannelWithUid(id)
+  if err != nil {
+    RespondWithError(ctx, err)
+    errMsgFormat := "Unexpected error when fetch subscribers for Channel with Id `%s` " +
+    "at Rexster backend: %v"
+    logger.Error(errMsgFormat, id, err)
     return
   }
   outChs := make([]GetChannelOutData, len(chs))
   for i := range chs {
-    outChs[i] = GetChannelOutData{chs[i].Id, chs[i].Name}
+    outChs[i] = NewGetChannelOutData(chs[i])
   }
   ctx.Response.Body = outChs
 }
\ No newline at end of file
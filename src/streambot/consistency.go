@@ -0,0 +1,287 @@
+package streambot
+
+import(
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+/* Titan offers no unique index on the `uid` property of Channel vertices, so nothing prevents 
+ * concurrent or retried writes from storing a Channel twice. Once duplicated, the Channel cannot 
+ * be read anymore as GetVertexWithUid refuses to pick one of the vertices. Likewise removing 
+ * vertices outside of DeleteChannel can leave `subscribe` edges attached to vertices that are no 
+ * Channel. The ConsistencyChecker finds both kinds of damage and merges duplicated Channels. */
+
+type ConsistencyChecker interface {
+	CheckConsistency() (err error, report ConsistencyReport)
+	// Keeps a single vertex for the uid and moves the subscriptions of all others onto it
+	MergeDuplicateUid(uid string) (err error, survivorId string)
+}
+
+// A uid stored on more than one vertex
+type DuplicateUid struct {
+	Uid			string
+	VertexIds	[]string
+}
+
+// A subscription edge of which at least one end is not a Channel vertex
+type DanglingSubscription struct {
+	EdgeId		string
+	FromUid		string
+	ToUid		string
+}
+
+type ConsistencyReport struct {
+	DuplicateUids			[]DuplicateUid
+	DanglingSubscriptions	[]DanglingSubscription
+}
+
+func (report ConsistencyReport) Consistent() bool {
+	return len(report.DuplicateUids) == 0 && len(report.DanglingSubscriptions) == 0
+}
+
+func (db *GraphDatabase) CheckConsistency() (err error, report ConsistencyReport) {
+	// Group all Channel vertex ids by uid in a single scan of the vertices
+	script := "m=[:];g.V.has(\"uid\").each{m.get(it.uid,[]).add(it.id.toString())};" +
+	"m.findAll{it.value.size()>1}.collect{[uid:it.key,ids:it.value.sort()]}.sort{it.uid}"
+	res, err := db.Eval(script, nil)
+	if err != nil {
+		err = WrapError(err, "Failed to query duplicate Channel uids at Rexster")
+		return
+	}
+	if res == nil {
+		err = ErrBackendUnavailable
+		return
+	}
+	for _, row := range res.Results {
+		m, ok := row.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var dup DuplicateUid
+		dup.Uid, _ = m["uid"].(string)
+		ids, _ := m["ids"].([]interface{})
+		for _, id := range ids {
+			dup.VertexIds = append(dup.VertexIds, fmt.Sprint(id))
+		}
+		report.DuplicateUids = append(report.DuplicateUids, dup)
+	}
+	script = "g.E.has(\"label\",\"subscribe\").transform{[id:it.id.toString()," +
+	"from:it.outV.next().uid,to:it.inV.next().uid]}.filter{it.from==null||it.to==null}"
+	res, err = db.Eval(script, nil)
+	if err != nil {
+		err = WrapError(err, "Failed to query dangling Channel subscriptions at Rexster")
+		return
+	}
+	if res == nil {
+		err = ErrBackendUnavailable
+		return
+	}
+	for _, row := range res.Results {
+		m, ok := row.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var sub DanglingSubscription
+		sub.EdgeId, _ = m["id"].(string)
+		sub.FromUid, _ = m["from"].(string)
+		sub.ToUid, _ = m["to"].(string)
+		report.DanglingSubscriptions = append(report.DanglingSubscriptions, sub)
+	}
+	return
+}
+
+// A vertex holding a uid stored on more than one vertex, with the subscription edges attached
+type DuplicateVertex struct {
+	Id			string
+	Version		int64
+	UpdatedAt	int64
+	// Subscriptions of the vertex, to the vertex at the other end of the edge
+	Out			[]VertexEdge
+	// Subscriptions to the vertex, from the vertex at the other end of the edge
+	In			[]VertexEdge
+}
+
+type VertexEdge struct {
+	Id			string
+	VertexId	string
+	// The properties present on the edge, edges stored before subscriptions were timed lack the 
+	// creation time
+	Properties	map[string]interface{}
+}
+
+// A subscription edge to be added between two vertices
+type PlannedEdge struct {
+	From		string					`json:"from"`
+	To			string					`json:"to"`
+	Properties	map[string]interface{}	`json:"properties"`
+}
+
+// Changes to the graph which merge the vertices of a duplicated uid into a single survivor
+type MergePlan struct {
+	SurvivorId		string			`json:"survivor"`
+	AddEdges		[]PlannedEdge	`json:"add_edges"`
+	RemoveEdges		[]string		`json:"remove_edges"`
+	RemoveVertices	[]string		`json:"remove_vertices"`
+}
+
+// Plans the merge of the vertices of a duplicated uid. The vertex with the most recent version 
+// survives, ties are broken by the most recent update and then by the vertex id. Edges between 
+// the duplicates themselves as well as edges the survivor already has are dropped instead of 
+// being moved, so that no self subscriptions or repeated subscriptions come up. Moved edges keep 
+// exactly the properties they have.
+func PlanMerge(vs []DuplicateVertex) (plan MergePlan) {
+	if len(vs) == 0 {
+		return
+	}
+	sorted := make([]DuplicateVertex, len(vs))
+	copy(sorted, vs)
+	sort.Sort(duplicateVerticesBySurvival(sorted))
+	survivor := sorted[0]
+	plan.SurvivorId = survivor.Id
+	duplicates := make(map[string]bool)
+	for _, v := range sorted {
+		duplicates[v.Id] = true
+	}
+	subscriptions := make(map[string]bool)
+	for _, e := range survivor.Out {
+		subscriptions[e.VertexId] = true
+	}
+	subscribers := make(map[string]bool)
+	for _, e := range survivor.In {
+		subscribers[e.VertexId] = true
+	}
+	removed := make(map[string]bool)
+	removeEdge := func(id string) {
+		if !removed[id] {
+			removed[id] = true
+			plan.RemoveEdges = append(plan.RemoveEdges, id)
+		}
+	}
+	for _, v := range sorted[1:] {
+		for _, e := range v.Out {
+			if !duplicates[e.VertexId] && !subscriptions[e.VertexId] {
+				subscriptions[e.VertexId] = true
+				plan.AddEdges = append(plan.AddEdges, PlannedEdge{survivor.Id, e.VertexId, e.Properties})
+			}
+			removeEdge(e.Id)
+		}
+		for _, e := range v.In {
+			if !duplicates[e.VertexId] && !subscribers[e.VertexId] {
+				subscribers[e.VertexId] = true
+				plan.AddEdges = append(plan.AddEdges, PlannedEdge{e.VertexId, survivor.Id, e.Properties})
+			}
+			removeEdge(e.Id)
+		}
+		plan.RemoveVertices = append(plan.RemoveVertices, v.Id)
+	}
+	return
+}
+
+type duplicateVerticesBySurvival []DuplicateVertex
+
+func (vs duplicateVerticesBySurvival) Len() int      { return len(vs) }
+func (vs duplicateVerticesBySurvival) Swap(i, j int) { vs[i], vs[j] = vs[j], vs[i] }
+func (vs duplicateVerticesBySurvival) Less(i, j int) bool {
+	if vs[i].Version != vs[j].Version {
+		return vs[i].Version > vs[j].Version
+	}
+	if vs[i].UpdatedAt != vs[j].UpdatedAt {
+		return vs[i].UpdatedAt > vs[j].UpdatedAt
+	}
+	return vs[i].Id < vs[j].Id
+}
+
+func (db *GraphDatabase) MergeDuplicateUid(uid string) (err error, survivorId string) {
+	err, vs := db.getDuplicateVertices(uid)
+	if err != nil {
+		return
+	}
+	if len(vs) == 0 {
+		err = ErrChannelNotFound
+		return
+	}
+	plan := PlanMerge(vs)
+	if len(plan.RemoveVertices) == 0 {
+		survivorId = plan.SurvivorId
+		return
+	}
+	// The plan is handed over as JSON document, as Rexster binds script parameters as flat values
+	buf, err := json.Marshal(plan)
+	if err != nil {
+		err = errors.New(fmt.Sprintf("Unexpected error when encoding merge of uid `%s`: %v", uid, err))
+		return
+	}
+	script := "p=new groovy.json.JsonSlurper().parseText(plan);" +
+	"p.add_edges.each{g.addEdge(g.v(it.from),g.v(it.to),\"subscribe\",it.properties)};" +
+	"p.remove_edges.each{g.removeEdge(g.e(it))};p.remove_vertices.each{g.removeVertex(g.v(it))};" +
+	"p.survivor"
+	res, err := db.EvalWrite(script, map[string]interface{}{"plan": string(buf)})
+	if err != nil {
+		err = WrapError(err, fmt.Sprintf("Failed to merge Channel vertices with uid `%s`", uid))
+		return
+	}
+	if res == nil {
+		err = ErrBackendUnavailable
+		return
+	}
+	if len(res.Results) == 1 {
+		survivorId, _ = res.Results[0].(string)
+	}
+	if survivorId == "" {
+		err = ErrChannelNotFound
+	}
+	return
+}
+
+// Reads the vertices holding the uid together with all their subscription edges
+func (db *GraphDatabase) getDuplicateVertices(uid string) (err error, vs []DuplicateVertex) {
+	script := "g.V(\"uid\",uid).transform{v->[id:v.id.toString(),version:v.version?:1," +
+	"updated_at:v.updated_at?:0,out:v.outE(\"subscribe\").transform{e->[id:e.id.toString()," +
+	"vertex_id:e.inV.next().id.toString(),properties:e.map()]}.toList(),in:v.inE(\"subscribe\")" +
+	".transform{e->[id:e.id.toString(),vertex_id:e.outV.next().id.toString(),properties:e.map()]}" +
+	".toList()]}"
+	res, err := db.Eval(script, map[string]interface{}{"uid": uid})
+	if err != nil {
+		err = WrapError(err, fmt.Sprintf("Failed to query Channel vertices with uid `%s`", uid))
+		return
+	}
+	if res == nil {
+		err = ErrBackendUnavailable
+		return
+	}
+	for _, row := range res.Results {
+		m, ok := row.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var v DuplicateVertex
+		v.Id, _ = m["id"].(string)
+		v.Version = int64FromMap(m, "version")
+		v.UpdatedAt = int64FromMap(m, "updated_at")
+		v.Out = vertexEdgesFromList(m["out"])
+		v.In = vertexEdgesFromList(m["in"])
+		vs = append(vs, v)
+	}
+	return
+}
+
+func vertexEdgesFromList(list interface{}) (edges []VertexEdge) {
+	rows, _ := list.([]interface{})
+	for _, row := range rows {
+		m, ok := row.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var e VertexEdge
+		e.Id, _ = m["id"].(string)
+		e.VertexId, _ = m["vertex_id"].(string)
+		e.Properties, _ = m["properties"].(map[string]interface{})
+		if e.Properties == nil {
+			e.Properties = make(map[string]interface{})
+		}
+		edges = append(edges, e)
+	}
+	return
+}
\ No newline at end of file
@@ -3,14 +3,60 @@ package streambot
 import(
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 )
 import rexster "github.com/mbiermann/go-rexster-client"
 
 type Database interface {
 	SaveChannel(ch *Channel) (err error)
 	GetChannelWithUid(uid string) (err error, ch *Channel)
+	UpdateChannel(uid string, version int64, patch ChannelPatch) (err error, ch *Channel)
+	ListChannels(filter ChannelFilter, order ChannelSort, page Page) (err error, chs []Channel)
+	// Subscribing twice keeps the time of the first subscription
 	SaveChannelSubscription(fromChannelId string, toChannelId string, creationTime int64) (err error)
-	GetSubscriptionsForChannelWithUid(uid string) (err error, chs []Channel)
+	GetSubscriptionsForChannelWithUid(uid string, depth int, page Page) (err error, subs []Subscription)
+	GetSubscribersForChannelWithUid(uid string) (err error, chs []Channel)
+	// Drops every incoming and outgoing subscription of the Channel together with it
+	DeleteChannel(uid string, version int64) (err error)
+	DeleteChannelSubscription(fromChannelId string, toChannelId string) (err error)
+}
+
+// Errors returned by Database implementations, callers are supposed to test for them with errors.Is
+var (
+	// No Channel exists for a given uid
+	ErrChannelNotFound = NewError(ErrorCodeNotFound, "Channel not found", nil)
+	// More than one Channel exists for a given uid, which only the consistency check can repair
+	ErrDuplicateUid = NewError(ErrorCodeInternal, "Duplicate Channel uid", nil)
+	// The backend storing the Channels cannot be reached
+	ErrBackendUnavailable = NewError(ErrorCodeBackendUnavailable, "Database backend unavailable", nil)
+	// A Channel is not at the version a change was requested for
+	ErrVersionMismatch = NewError(ErrorCodePreconditionFailed, "Channel version does not match", nil)
+)
+
+/* Updating and deleting Channels is conditional on their version, so that concurrent writers 
+ * cannot silently overwrite each others changes. Implementations check the version and apply 
+ * the change atomically, a version of 0 applies the change to whatever version is stored. */
+
+// Times an update without a version is retried when the Channel changed while it was applied
+const UnconditionalUpdateRetries = 5
+
+// The Channel kept changing concurrently while an update without a version was applied
+var errConcurrentUpdates = NewError(ErrorCodeConflict, "Channel changed concurrently, try again", nil)
+
+// Runs an update which reads and writes back the Channel. Without a version the update is retried 
+// when the Channel changed in between, as ErrVersionMismatch only makes sense for conditional 
+// updates.
+func retryUnconditionalUpdate(version int64, update func() (err error, ch *Channel)) (err error, ch *Channel) {
+	for retries := 0; ; retries++ {
+		err, ch = update()
+		if version != 0 || !errors.Is(err, ErrVersionMismatch) {
+			return
+		}
+		if retries == UnconditionalUpdateRetries {
+			return errConcurrentUpdates, nil
+		}
+	}
 }
 
 /* At time of development there is a specialy to consider about the rexster backend server. As 
@@ -22,6 +68,10 @@ type Database interface {
 
 type GraphDatabase struct {
 	Graph rexster.Graph
+	Hosts []string
+	// Logs with the fields of the request the database is used for, if any
+	Logger *Logger
+	hosts *hostPool
 }
 
 func NewGraphDatabase(graph_name string, hosts []string) (db *GraphDatabase, err error) {
@@ -36,19 +86,33 @@ func NewGraphDatabase(graph_name string, hosts []string) (db *GraphDatabase, err
 		return
 	}
 	var g = rexster.Graph{graph_name, *r}
-	db = &GraphDatabase{g}
+	db = &GraphDatabase{g, hosts, nil, newHostPool(DefaultHostReanimationAfter)}
 	return
 }
 
+func (db *GraphDatabase) WithLogger(logger *Logger) Database {
+	scoped := *db
+	scoped.Logger = logger
+	return &scoped
+}
+
 func (db *GraphDatabase) SaveChannel(ch *Channel) (err error) {
 	// Create a vertex in the graph database for the channel
-	var properties = map[string]interface{}{"name": ch.Name, "uid": ch.Id}
+	var properties = map[string]interface{}{
+		"uid": ch.Id,
+		"name": ch.Name,
+		"description": ch.Description,
+		"owner_id": ch.OwnerId,
+		"created_at": ch.CreatedAt,
+		"updated_at": ch.UpdatedAt,
+		"version": ch.Version,
+	}
 	vertex := rexster.NewVertex("", properties)
 	_, err = db.Graph.CreateOrUpdateVertex(vertex)
-	fmt.Println(fmt.Sprintf("Vertex is %v", vertex))
+	db.Logger.Debug("Saved Channel vertex %v", vertex)
 	if err != nil {
-		errMsgFormat := "Unexpected error when saving Channel vertex `%v`: %v"
-		err = errors.New(fmt.Sprintf(errMsgFormat, vertex, err))
+		db.Logger.WithError(err).Error("Unexpected error when saving Channel vertex %v at Rexster", vertex)
+		err = NewBackendUnavailableError("Failed to save Channel at Rexster", err)
 	}
 	return
 }
@@ -56,26 +120,21 @@ func (db *GraphDatabase) SaveChannel(ch *Channel) (err error) {
 func GetVertexWithUid(db *GraphDatabase, uid string) (v *rexster.Vertex, err error) {
 	res, err := db.Graph.QueryVertices("uid", uid)
 	if err != nil {
-		err = errors.New(fmt.Sprintf("Failed to query vertices at Rexster with error: %v", err))
+		err = NewBackendUnavailableError("Failed to query vertices at Rexster", err)
 		return
 	}
 	if res == nil {
-		err = errors.New(fmt.Sprintf("Rexster backend did not respond"))
+		err = ErrBackendUnavailable
 		return
 	}
-	if vs := res.Vertices(); vs != nil {
-		numVertices := len(vs)
-		if numVertices > 1 {
-			errMsgFormat := "Unexpectedly Rexster backend returned more than one vertex, given `%v`"
-			errMsg := fmt.Sprintf(errMsgFormat, vs)
-			err = errors.New(errMsg)
-		} else if numVertices == 1 {
-			v = vs[0]
-		} else {
-			errMsgFormat := "Unexpectedly Rexster backend returned no vertex, given `%v`"
-			errMsg := fmt.Sprintf(errMsgFormat, res)
-			err = errors.New(errMsg)
-		}
+	vs := res.Vertices()
+	if numVertices := len(vs); numVertices > 1 {
+		errMsgFormat := "Rexster backend returned %d vertices with uid `%s`"
+		err = WrapError(ErrDuplicateUid, fmt.Sprintf(errMsgFormat, numVertices, uid))
+	} else if numVertices == 1 {
+		v = vs[0]
+	} else {
+		err = ErrChannelNotFound
 	}
 	return
 }
@@ -83,10 +142,93 @@ func GetVertexWithUid(db *GraphDatabase, uid string) (v *rexster.Vertex, err err
 func (db *GraphDatabase) GetChannelWithUid(uid string) (err error, ch *Channel) {
 	vertex, err := GetVertexWithUid(db, uid)
 	if err != nil {
-		err = errors.New(fmt.Sprintf("Failed to query vertices at Rexster with error: %v", err))
 		return
 	}
-	ch = &Channel{vertex.Map["uid"].(string), vertex.Map["name"].(string)}
+	c := ChannelFromMap(vertex.Map)
+	ch = &c
+	return
+}
+
+func (db *GraphDatabase) UpdateChannel(uid string, version int64, patch ChannelPatch) (err error, ch *Channel) {
+	return retryUnconditionalUpdate(version, func() (error, *Channel) {
+		return db.updateChannel(uid, version, patch)
+	})
+}
+
+func (db *GraphDatabase) updateChannel(uid string, version int64, patch ChannelPatch) (err error, ch *Channel) {
+	err, ch = db.GetChannelWithUid(uid)
+	if err != nil {
+		return
+	}
+	if !ch.HasVersion(version) {
+		err, ch = ErrVersionMismatch, nil
+		return
+	}
+	current := ch.Version
+	patch.Apply(ch, time.Now().Unix())
+	// Write back all mutable properties, which also adds those missing on older vertices. The 
+	// script refuses to write when the vertex changed since it was read above.
+	script := "v=g.V(\"uid\",uid).next();if((v.version?:1)!=current){return false};" +
+	"v.setProperty(\"name\",name);v.setProperty(\"description\",description);" +
+	"v.setProperty(\"owner_id\",owner_id);v.setProperty(\"updated_at\",updated_at);" +
+	"v.setProperty(\"version\",current+1);true"
+	params := map[string]interface{}{
+		"uid": uid,
+		"current": current,
+		"name": ch.Name,
+		"description": ch.Description,
+		"owner_id": ch.OwnerId,
+		"updated_at": ch.UpdatedAt,
+	}
+	res, err := db.EvalWrite(script, params)
+	if err != nil {
+		err = WrapError(err, fmt.Sprintf("Unexpected error when updating Channel with Id `%s`", uid))
+		ch = nil
+	} else if isGremlinFalse(res) {
+		err, ch = ErrVersionMismatch, nil
+	}
+	return
+}
+
+// Whether a script reported to have refused a change by returning false
+func isGremlinFalse(res *GremlinResponse) bool {
+	return res != nil && len(res.Results) == 1 && res.Results[0] == false
+}
+
+func (db *GraphDatabase) ListChannels(
+	filter ChannelFilter, 
+	order ChannelSort, 
+	page Page,
+) (err error, chs []Channel) {
+	// Scan all Channel vertices, vertices which lack the sorted property are ordered first
+	script := "g.V.has(\"uid\").filter{(it.name?:\"\").toLowerCase().startsWith(prefix)}" +
+	".order{(it.a.getProperty(field) <=> it.b.getProperty(field))*(desc?-1:1) ?: " +
+	"it.a.uid <=> it.b.uid}[first..last]"
+	params := map[string]interface{}{
+		"prefix": strings.ToLower(filter.NamePrefix),
+		"field": string(order.SortField()),
+		"desc": order.Descending,
+		"first": page.Offset,
+		"last": -1,
+	}
+	if page.Limit > 0 {
+		params["last"] = page.Offset + page.Limit - 1
+	}
+	res, err := db.Eval(script, params)
+	if err != nil {
+		err = WrapError(err, "Failed to list channels at Rexster")
+		return
+	}
+	if res == nil {
+		err = ErrBackendUnavailable
+		return
+	}
+	chs = make([]Channel, 0, len(res.Results))
+	for _, row := range res.Results {
+		if vertex, ok := row.(map[string]interface{}); ok {
+			chs = append(chs, ChannelFromMap(vertex))
+		}
+	}
 	return
 }
 
@@ -95,40 +237,149 @@ func (db *GraphDatabase) SaveChannelSubscription(
 	toChannelId string, 
 	creationTime int64,
 ) (err error) {
-	format := "g.addEdge(g.V(\"uid\",\"%s\").next(),g.V(\"uid\",\"%s\").next(),\"subscribe\")"
-	_, err = db.Graph.Eval(fmt.Sprintf(format, fromChannelId, toChannelId))
+	// Keep the subscription's creation time as property of the edge, refuse subscriptions between 
+	// unknown channels by returning false. An existing edge is left as it is.
+	script := "f=g.V(\"uid\",from);t=g.V(\"uid\",to);if(!f.hasNext()||!t.hasNext()){return false};" +
+	"f=f.next();t=t.next();if(!f.outE(\"subscribe\").inV.has(\"uid\",to).hasNext()){" +
+	"g.addEdge(f,t,\"subscribe\",[created_at:created_at])};true"
+	params := map[string]interface{}{"from": fromChannelId, "to": toChannelId, "created_at": creationTime}
+	res, err := db.EvalWrite(script, params)
 	if err != nil {
-		errMsgFormat := "Unexpected error when saving Channel Subscription: %v"
-		err = errors.New(fmt.Sprintf(errMsgFormat, err))
+		err = WrapError(err, "Unexpected error when saving Channel Subscription")
+	} else if isGremlinFalse(res) {
+		err = ErrChannelNotFound
 	}
 	return
 }
 
-func (db *GraphDatabase) GetSubscriptionsForChannelWithUid(uid string) (err error, chs []Channel) {
-	scriptFormat := "g.V(\"uid\",\"%s\").as('x').out.loop('x'){it.loops < 5}{true}.dedup()"
-	script := fmt.Sprintf(scriptFormat, uid)
-	res, err := db.Graph.Eval(script)
+func (db *GraphDatabase) DeleteChannelSubscription(fromChannelId string, toChannelId string) (err error) {
+	// Removing no edge at all is fine, as the subscription might be gone already
+	script := "g.V(\"uid\",from).outE(\"subscribe\").filter{it.inV.next().uid==to}.toList()" +
+	".each{g.removeEdge(it)}"
+	_, err = db.EvalWrite(script, map[string]interface{}{"from": fromChannelId, "to": toChannelId})
 	if err != nil {
-		err = errors.New(fmt.Sprintf("Failed to query subscribed channels at Rexster:", err))
+		err = WrapError(err, "Unexpected error when deleting Channel Subscription")
+	}
+	return
+}
+
+func (db *GraphDatabase) GetSubscriptionsForChannelWithUid(
+	uid string, 
+	depth int,
+	page Page,
+) (err error, subs []Subscription) {
+	// Walk the subscription paths up to the given depth and emit every reached channel other than 
+	// the subscribing one together with its hop distance and the creation time of the last edge 
+	// that led to it. Channels reachable on several paths are only reported with their shortest 
+	// distance. The stable order by distance and uid allows to cut out the requested page at the 
+	// Rexster server already.
+	script := "g.V(\"uid\",uid).as('x').outE(\"subscribe\").inV.loop('x'){it.loops < depth}" +
+	"{true}.path.transform{[uid:it[-1].uid,name:it[-1].name,description:it[-1].description," +
+	"owner_id:it[-1].owner_id,channel_created_at:it[-1].created_at,channel_updated_at:" +
+	"it[-1].updated_at,created_at:it[-2].created_at,hops:(it.size()-1).intdiv(2)]}" +
+	".filter{it.uid != uid}" +
+	".order{it.a.hops <=> it.b.hops ?: it.a.uid <=> it.b.uid}.dedup{it.uid}[first..last]"
+	params := map[string]interface{}{"uid": uid, "depth": depth, "first": page.Offset, "last": -1}
+	if page.Limit > 0 {
+		params["last"] = page.Offset + page.Limit - 1
+	}
+	res, err := db.Eval(script, params)
+	if err != nil {
+		err = WrapError(err, "Failed to query subscribed channels at Rexster")
 		return
 	}
 	if res == nil {
-		err = errors.New(fmt.Sprintf("Rexster backend did not respond"))
+		err = ErrBackendUnavailable
 		return
 	}
-	if vs := res.Vertices(); vs != nil {
-		numVertices := len(vs)
-		if numVertices > 0 {
-			chs = make([]Channel, numVertices)
-			for idx, vertex := range vs {
-				chs[idx] = Channel{vertex.Map["uid"].(string), vertex.Map["name"].(string)}	
-			}
+	subs = make([]Subscription, 0, len(res.Results))
+	for _, row := range res.Results {
+		m, ok := row.(map[string]interface{})
+		if !ok {
+			continue
 		}
-	} else {
-		errMsgFormat := "Unexpectedly Rexster backend returned no vertex for channel " +
-		"subscription, given `%v`"
-		errMsg := fmt.Sprintf(errMsgFormat, res)
-		err = errors.New(errMsg)
+		if sub := SubscriptionFromMap(m); sub.Channel.Id != "" {
+			subs = append(subs, sub)
+		}
+	}
+	return
+}
+
+func (db *GraphDatabase) GetSubscribersForChannelWithUid(uid string) (err error, chs []Channel) {
+	// Only direct subscribers are of interest, so follow incoming subscription edges a single hop
+	script := "g.V(\"uid\",uid).in(\"subscribe\").dedup()"
+	res, err := db.Eval(script, map[string]interface{}{"uid": uid})
+	if err != nil {
+		err = WrapError(err, "Failed to query subscribing channels at Rexster")
+		return
+	}
+	if res == nil {
+		err = ErrBackendUnavailable
+		return
+	}
+	chs = make([]Channel, 0, len(res.Results))
+	for _, row := range res.Results {
+		vertex, ok := row.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		chs = append(chs, ChannelFromMap(vertex))
+	}
+	return
+}
+
+// Reads a Channel from the properties of its vertex, tolerating vertices which were stored before 
+// the Channel metadata was introduced
+func ChannelFromMap(m map[string]interface{}) (ch Channel) {
+	ch.Id, _ = m["uid"].(string)
+	ch.Name, _ = m["name"].(string)
+	ch.Description, _ = m["description"].(string)
+	ch.OwnerId, _ = m["owner_id"].(string)
+	ch.CreatedAt = int64FromMap(m, "created_at")
+	ch.UpdatedAt = int64FromMap(m, "updated_at")
+	ch.Version = int64FromMap(m, "version")
+	if ch.Version == 0 {
+		ch.Version = InitialChannelVersion
+	}
+	return
+}
+
+// Reads a Subscription from a result row, tolerating edges which lack a creation time
+func SubscriptionFromMap(m map[string]interface{}) (sub Subscription) {
+	sub.Channel.Id, _ = m["uid"].(string)
+	sub.Channel.Name, _ = m["name"].(string)
+	sub.Channel.Description, _ = m["description"].(string)
+	sub.Channel.OwnerId, _ = m["owner_id"].(string)
+	sub.Channel.CreatedAt = int64FromMap(m, "channel_created_at")
+	sub.Channel.UpdatedAt = int64FromMap(m, "channel_updated_at")
+	sub.CreatedAt = int64FromMap(m, "created_at")
+	if hops, ok := m["hops"].(float64); ok {
+		sub.Hops = int(hops)
+	}
+	return
+}
+
+// Numbers are decoded from Rexster JSON responses as float64
+func int64FromMap(m map[string]interface{}, key string) int64 {
+	if value, ok := m[key].(float64); ok {
+		return int64(value)
+	}
+	return 0
+}
+
+func (db *GraphDatabase) DeleteChannel(uid string, version int64) (err error) {
+	_, err = GetVertexWithUid(db, uid)
+	if err != nil {
+		return
+	}
+	// Drop every incoming and outgoing subscription edge before removing the channel vertex itself
+	script := "v=g.V(\"uid\",uid).next();if(version!=0&&(v.version?:1)!=version){return false};" +
+	"v.bothE(\"subscribe\").toList().each{g.removeEdge(it)};g.removeVertex(v);true"
+	res, err := db.EvalWrite(script, map[string]interface{}{"uid": uid, "version": version})
+	if err != nil {
+		err = WrapError(err, fmt.Sprintf("Unexpected error when deleting Channel with Id `%s`", uid))
+	} else if isGremlinFalse(res) {
+		err = ErrVersionMismatch
 	}
 	return
 }
\ No newline at end of file
@@ -0,0 +1,96 @@
+package streambot
+
+import(
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+/* Failures are classified by an ErrorCode, so that callers can tell a missing channel from an 
+ * unreachable backend without parsing messages. Database implementations return an *Error with 
+ * the code that fits best, any other error is considered internal. Errors callers test for with 
+ * errors.Is wrap one of the sentinel errors or are created as instance of one, sentinels match by 
+ * identity only. The API renders errors as 
+ * JSON envelope carrying the code, a human readable message and the id of the failed request. */
+
+type ErrorCode string
+
+const (
+	ErrorCodeNotFound ErrorCode = "not_found"
+	ErrorCodeInvalidArgument ErrorCode = "invalid_argument"
+	ErrorCodeValidationFailed ErrorCode = "validation_failed"
+	ErrorCodeConflict ErrorCode = "conflict"
+	ErrorCodePreconditionFailed ErrorCode = "precondition_failed"
+	ErrorCodeBackendUnavailable ErrorCode = "backend_unavailable"
+	ErrorCodeInternal ErrorCode = "internal"
+)
+
+// HTTP status code responded for errors of the code
+func (code ErrorCode) HTTPStatus() int {
+	switch code {
+	case ErrorCodeNotFound:
+		return http.StatusNotFound
+	case ErrorCodeInvalidArgument:
+		return http.StatusBadRequest
+	case ErrorCodeValidationFailed:
+		return http.StatusUnprocessableEntity
+	case ErrorCodeConflict:
+		return http.StatusConflict
+	case ErrorCodePreconditionFailed:
+		return http.StatusPreconditionFailed
+	case ErrorCodeBackendUnavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+type Error struct {
+	Code		ErrorCode
+	Message		string
+	// The underlying error that caused this one, if any
+	Err			error
+	// The fields of a request that failed validation, if any
+	Details		[]FieldError
+	// The sentinel error this one is an instance of, if any
+	sentinel	*Error
+}
+
+func NewError(code ErrorCode, message string, err error) *Error {
+	return &Error{Code: code, Message: message, Err: err}
+}
+
+// Wraps err with a message, keeping the code of err
+func WrapError(err error, message string) *Error {
+	return &Error{Code: ErrorCodeOf(err), Message: message, Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Matches the sentinel the error was created as an instance of
+func (e *Error) Is(target error) bool {
+	return e.sentinel != nil && target == error(e.sentinel)
+}
+
+// Error of a backend that cannot be reached because of err. The message is shown to clients, so it 
+// must not tell details of the backend, which err keeps for logging and errors.Is instead.
+func NewBackendUnavailableError(message string, err error) *Error {
+	return &Error{Code: ErrorCodeBackendUnavailable, Message: message, Err: err, sentinel: ErrBackendUnavailable}
+}
+
+// Code of the first *Error in the chain of err, ErrorCodeInternal if there is none
+func ErrorCodeOf(err error) ErrorCode {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return ErrorCodeInternal
+}
\ No newline at end of file
@@ -0,0 +1,50 @@
+package streambot
+
+import(
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+/* Channel representations are tagged with the version of the Channel, so clients can make their 
+ * changes conditional on the version they have seen with `If-Match` and revalidate cached 
+ * representations with `If-None-Match`. */
+
+// Strong entity tag of the Channel's current version
+func ChannelETag(ch *Channel) string {
+	return fmt.Sprintf("\"%d\"", ch.Version)
+}
+
+// Reads the Channel version a change is conditional on from the `If-Match` header of a request. 
+// Version 0 is returned when the change is unconditional. Only a single strong entity tag is 
+// supported, anything else cannot match and results in an error.
+func VersionFromIfMatch(r *http.Request) (version int64, err error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return
+	}
+	if len(header) > 2 && header[0] == '"' && header[len(header) - 1] == '"' {
+		version, err = strconv.ParseInt(header[1:len(header) - 1], 10, 64)
+		if err == nil && version > 0 {
+			return
+		}
+	}
+	version, err = 0, errors.New(fmt.Sprintf("Entity tag `%s` does not match any Channel version", header))
+	return
+}
+
+// Whether the `If-None-Match` header of a request matches the entity tag, comparing weakly
+func IfNoneMatch(r *http.Request, etag string) bool {
+	header := strings.TrimSpace(r.Header.Get("If-None-Match"))
+	if header == "*" {
+		return true
+	}
+	for _, tag := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
\ No newline at end of file
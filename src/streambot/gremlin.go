@@ -0,0 +1,201 @@
+package streambot
+
+import(
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+/* Scripts are sent to the Gremlin extension of Rexster with all variable data bound as script 
+ * parameters instead of being formatted into the script source. That way ids and names coming in 
+ * from API requests are always treated as literal data by the Rexster script engine and can never 
+ * alter the script itself. */
+
+// Response of the Rexster Gremlin extension
+type GremlinResponse struct {
+	Results		[]interface{}	`json:"results"`
+	Success		bool			`json:"success"`
+	Message		string			`json:"message"`
+}
+
+type gremlinRequest struct {
+	Script		string					`json:"script"`
+	Params		map[string]interface{}	`json:"params,omitempty"`
+}
+
+var gremlinClient = &http.Client{Timeout: 10 * time.Second}
+
+// Time a Rexster host that could not be reached is tried after all others, like the reanimation 
+// of nodes by the rexster client
+const DefaultHostReanimationAfter = 300 * time.Second
+
+// Keeps track of the Rexster hosts that recently failed to respond
+type hostPool struct {
+	lock				sync.Mutex
+	failedAt			map[string]time.Time
+	reanimationAfter	time.Duration
+}
+
+func newHostPool(reanimationAfter time.Duration) *hostPool {
+	return &hostPool{failedAt: make(map[string]time.Time), reanimationAfter: reanimationAfter}
+}
+
+// Hosts in the order to try them, hosts failed within the reanimation time come last so that an 
+// unresponsive host only delays scripts when no other host responds
+func (p *hostPool) order(hosts []string) []string {
+	if p == nil {
+		return hosts
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	live := make([]string, 0, len(hosts))
+	var failed []string
+	for _, host := range hosts {
+		if failedAt, ok := p.failedAt[host]; ok && time.Since(failedAt) < p.reanimationAfter {
+			failed = append(failed, host)
+		} else {
+			live = append(live, host)
+		}
+	}
+	return append(live, failed...)
+}
+
+func (p *hostPool) fail(host string) {
+	if p == nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.failedAt[host] = time.Now()
+}
+
+func (p *hostPool) reanimate(host string) {
+	if p == nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	delete(p.failedAt, host)
+}
+
+// Evaluates a Gremlin script which only reads the graph, with the given parameters bound as 
+// script variables. The Rexster hosts are tried in turn until one of them responds, starting with 
+// those that did not fail recently.
+func (db *GraphDatabase) Eval(script string, params map[string]interface{}) (res *GremlinResponse, err error) {
+	return db.eval(script, params, true)
+}
+
+// Evaluates a Gremlin script which changes the graph. Unlike Eval the script is only sent to the 
+// next Rexster host if the previous one could not be connected to, as a host failing to respond 
+// may have applied the changes already.
+func (db *GraphDatabase) EvalWrite(script string, params map[string]interface{}) (res *GremlinResponse, err error) {
+	return db.eval(script, params, false)
+}
+
+func (db *GraphDatabase) eval(
+	script string, 
+	params map[string]interface{}, 
+	retryable bool,
+) (res *GremlinResponse, err error) {
+	body, err := json.Marshal(gremlinRequest{script, params})
+	if err != nil {
+		err = errors.New(fmt.Sprintf("Unexpected error when encoding Gremlin request: %v", err))
+		return
+	}
+	if len(db.Hosts) == 0 {
+		err = NewBackendUnavailableError("No Rexster hosts configured", nil)
+		return
+	}
+	for _, host := range db.hosts.order(db.Hosts) {
+		logger := db.Logger.With(Fields{"rexster_host": host})
+		url := fmt.Sprintf("http://%s/graphs/%s/tp/gremlin", host, db.Graph.Name)
+		beforeEval := time.Now()
+		res, err = postGremlinRequest(url, body)
+		if err == nil {
+			db.hosts.reanimate(host)
+			logger.Debug("Evaluated Gremlin script `%s` in %v", script, time.Since(beforeEval))
+			return
+		}
+		if _, ok := err.(gremlinScriptError); ok {
+			// The script failed at a responding host, so trying the others won't help
+			db.hosts.reanimate(host)
+			logger.WithError(err).Error("Failed to evaluate Gremlin script `%s`", script)
+			return
+		}
+		db.hosts.fail(host)
+		if _, unsent := err.(gremlinConnectError); !unsent && !retryable {
+			logger.WithError(err).Error("Rexster host unavailable, not retrying Gremlin script `%s`", script)
+			break
+		}
+		logger.WithError(err).Warning("Rexster host unavailable, trying the next one")
+	}
+	err = NewBackendUnavailableError("No Rexster host reachable to evaluate Gremlin script", err)
+	return
+}
+
+// Error reported by a Rexster host that responded but failed to evaluate a script
+type gremlinScriptError string
+
+func (e gremlinScriptError) Error() string {
+	return string(e)
+}
+
+// Error of a Rexster host that could not be connected to, so the script was not sent at all
+type gremlinConnectError string
+
+func (e gremlinConnectError) Error() string {
+	return string(e)
+}
+
+func postGremlinRequest(url string, body []byte) (res *GremlinResponse, err error) {
+	resp, err := gremlinClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			err = gremlinConnectError(fmt.Sprintf("Failed to connect to `%s`: %v", url, err))
+		} else {
+			err = errors.New(fmt.Sprintf("Failed to send Gremlin script to `%s`: %v", url, err))
+		}
+		return
+	}
+	defer resp.Body.Close()
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		err = errors.New(fmt.Sprintf("Failed to read Gremlin response from `%s`: %v", url, err))
+		return
+	}
+	if resp.StatusCode == http.StatusOK {
+		res = new(GremlinResponse)
+		if err = json.Unmarshal(raw, res); err != nil {
+			err = errors.New(fmt.Sprintf("Invalid Gremlin response from `%s`: %s", url, string(raw)))
+			res = nil
+		}
+		return
+	}
+	// Rexster reports failed scripts in a JSON body, any other response comes from a host or a 
+	// proxy in front of it that cannot serve the request right now
+	var failure gremlinFailure
+	isScriptFailure := (resp.StatusCode == http.StatusBadRequest || 
+		resp.StatusCode == http.StatusInternalServerError) && 
+		json.Unmarshal(raw, &failure) == nil && (failure.Message != "" || failure.Error != "")
+	if isScriptFailure {
+		errMsgFormat := "Rexster at `%s` failed to evaluate Gremlin script with status %d: %s"
+		err = gremlinScriptError(fmt.Sprintf(errMsgFormat, url, resp.StatusCode, string(raw)))
+	} else {
+		errMsgFormat := "Rexster at `%s` responded with status %d: %s"
+		err = errors.New(fmt.Sprintf(errMsgFormat, url, resp.StatusCode, string(raw)))
+	}
+	return
+}
+
+// Body of a Rexster response to a failed script
+type gremlinFailure struct {
+	Message	string	`json:"message"`
+	Error	string	`json:"error"`
+}
\ No newline at end of file
@@ -0,0 +1,91 @@
+package streambot
+
+import(
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/* Channels are listed filtered by a name prefix and ordered by name or creation time. Channels 
+ * that are equal in the sorted field are ordered by uid, which keeps the order stable while 
+ * clients page through a listing. */
+
+// Restricts a listing to Channels whose name starts with NamePrefix, ignoring case
+type ChannelFilter struct {
+	NamePrefix	string
+}
+
+func (filter ChannelFilter) Matches(ch *Channel) bool {
+	return strings.HasPrefix(strings.ToLower(ch.Name), strings.ToLower(filter.NamePrefix))
+}
+
+type ChannelSortField string
+
+const (
+	SortChannelsByName ChannelSortField = "name"
+	SortChannelsByCreatedAt ChannelSortField = "created_at"
+)
+
+// The zero ChannelSort orders by name ascending
+type ChannelSort struct {
+	Field		ChannelSortField
+	Descending	bool
+}
+
+// The field to sort by, defaulting to the name
+func (order ChannelSort) SortField() ChannelSortField {
+	if order.Field == "" {
+		return SortChannelsByName
+	}
+	return order.Field
+}
+
+// Reads a sort order given as field name, prefixed with `-` for descending order. An empty 
+// order sorts by name.
+func ParseChannelSort(s string) (order ChannelSort, err error) {
+	order.Descending = strings.HasPrefix(s, "-")
+	order.Field = ChannelSortField(strings.TrimPrefix(s, "-"))
+	switch order.SortField() {
+	case SortChannelsByName, SortChannelsByCreatedAt:
+	default:
+		errMsgFormat := "Cannot sort Channels by `%s`, only by `%s` or `%s`"
+		err = errors.New(fmt.Sprintf(errMsgFormat, s, SortChannelsByName, SortChannelsByCreatedAt))
+	}
+	return
+}
+
+func (order ChannelSort) Less(a *Channel, b *Channel) bool {
+	var cmp int
+	if order.SortField() == SortChannelsByName {
+		cmp = strings.Compare(a.Name, b.Name)
+	} else if a.CreatedAt != b.CreatedAt {
+		cmp = 1
+		if a.CreatedAt < b.CreatedAt {
+			cmp = -1
+		}
+	}
+	if cmp == 0 {
+		return a.Id < b.Id
+	}
+	return (cmp < 0) != order.Descending
+}
+
+// Filters, sorts and pages a listing of Channels held in memory
+func listChannels(chs []Channel, filter ChannelFilter, order ChannelSort, page Page) []Channel {
+	matching := make([]Channel, 0, len(chs))
+	for i := range chs {
+		if filter.Matches(&chs[i]) {
+			matching = append(matching, chs[i])
+		}
+	}
+	sort.Slice(matching, func(i, j int) bool { return order.Less(&matching[i], &matching[j]) })
+	if page.Offset >= len(matching) {
+		return []Channel{}
+	}
+	matching = matching[page.Offset:]
+	if page.Limit > 0 && page.Limit < len(matching) {
+		matching = matching[:page.Limit]
+	}
+	return matching
+}
\ No newline at end of file
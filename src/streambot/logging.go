@@ -0,0 +1,181 @@
+package streambot
+
+import(
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"github.com/op/go-logging"
+	"code.google.com/p/go-uuid/uuid"
+)
+
+/* Log lines carry fields next to their message, like the id of the request they were logged for, 
+ * so that the lines of concurrent requests can be told apart. A Logger holds the fields and hands 
+ * them to go-logging as a LogEntry, which the text format appends to the message as `key=value` 
+ * pairs and the JSONFormatter writes as properties of a JSON object. */
+
+type Fields map[string]interface{}
+
+type Logger struct {
+	Fields Fields
+}
+
+func NewLogger(fields Fields) *Logger {
+	return &Logger{fields}
+}
+
+// Logger with the given fields added, a nil Logger logs without fields
+func (l *Logger) With(fields Fields) *Logger {
+	merged := make(Fields)
+	if l != nil {
+		for key, value := range l.Fields {
+			merged[key] = value
+		}
+	}
+	for key, value := range fields {
+		merged[key] = value
+	}
+	return &Logger{merged}
+}
+
+func (l *Logger) WithError(err error) *Logger {
+	return l.With(Fields{"error": err.Error()})
+}
+
+func (l *Logger) entry(format string, args []interface{}) LogEntry {
+	entry := LogEntry{Message: fmt.Sprintf(format, args...)}
+	if l != nil {
+		entry.Fields = l.Fields
+	}
+	return entry
+}
+
+func (l *Logger) Debug(format string, args ...interface{}) {
+	log.Debug("%v", l.entry(format, args))
+}
+
+func (l *Logger) Info(format string, args ...interface{}) {
+	log.Info("%v", l.entry(format, args))
+}
+
+func (l *Logger) Warning(format string, args ...interface{}) {
+	log.Warning("%v", l.entry(format, args))
+}
+
+func (l *Logger) Error(format string, args ...interface{}) {
+	log.Error("%v", l.entry(format, args))
+}
+
+type LogEntry struct {
+	Message	string
+	Fields	Fields
+}
+
+// Message followed by the fields in order of their names
+func (e LogEntry) String() string {
+	if len(e.Fields) == 0 {
+		return e.Message
+	}
+	keys := make([]string, 0, len(e.Fields))
+	for key := range e.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = fmt.Sprintf("%s=%v", key, e.Fields[key])
+	}
+	return e.Message + " " + strings.Join(pairs, " ")
+}
+
+// Entry logged through a Logger, records logged with go-logging directly have none
+func logEntryOfRecord(r *logging.Record) (entry LogEntry, ok bool) {
+	if len(r.Args) == 1 {
+		entry, ok = r.Args[0].(LogEntry)
+	}
+	return
+}
+
+// Formats go-logging records as JSON objects, one per line. The fields of an entry are written 
+// next to time, level, module and message, which take precedence.
+type JSONFormatter struct {}
+
+func (JSONFormatter) Format(calldepth int, r *logging.Record, w io.Writer) error {
+	line := make(map[string]interface{})
+	var message string
+	if entry, ok := logEntryOfRecord(r); ok {
+		message = entry.Message
+		for key, value := range entry.Fields {
+			line[key] = value
+		}
+	} else {
+		message = r.Message()
+	}
+	line["time"] = r.Time.Format(time.RFC3339Nano)
+	line["level"] = r.Level.String()
+	line["module"] = r.Module
+	line["message"] = message
+	return json.NewEncoder(w).Encode(line)
+}
+
+/* Every request gets a RequestScope in the context of its http.Request, which ripple hands on to 
+ * the controllers with the ripple.Context. The scope holds the id of the request, as given by the 
+ * client in the `X-Request-Id` header or newly generated, and the Logger for the request. */
+
+type RequestScope struct {
+	Id		string
+	Logger	*Logger
+	// Error responded to the client, if any
+	Err		error
+}
+
+type requestScopeKey struct {}
+
+// Client given request ids are only accepted if they cannot garble log lines
+var requestIdPattern = regexp.MustCompile("^[A-Za-z0-9._:-]{1,128}$")
+
+func newRequestScope(r *http.Request, route string) *RequestScope {
+	id := r.Header.Get("X-Request-Id")
+	if !requestIdPattern.MatchString(id) {
+		id = uuid.New()
+	}
+	fields := Fields{"request_id": id}
+	if route != "" {
+		fields["route"] = route
+	}
+	return &RequestScope{Id: id, Logger: NewLogger(fields)}
+}
+
+// Scope of the request, or a new one if the request did not pass WithRequestScope
+func RequestScopeFromRequest(r *http.Request) *RequestScope {
+	if scope, ok := r.Context().Value(requestScopeKey{}).(*RequestScope); ok {
+		return scope
+	}
+	return newRequestScope(r, "")
+}
+
+// Puts a RequestScope into the context of every request and responds its id
+func WithRequestScope(basePath string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scope := newRequestScope(r, RouteLabel(r, basePath))
+		w.Header().Set("X-Request-Id", scope.Id)
+		handler(w, r.WithContext(context.WithValue(r.Context(), requestScopeKey{}, scope)))
+	}
+}
+
+// Implemented by Databases which log, to log with the fields of a request
+type ScopedDatabase interface {
+	WithLogger(logger *Logger) Database
+}
+
+func DatabaseWithLogger(db Database, logger *Logger) Database {
+	if scoped, ok := db.(ScopedDatabase); ok {
+		return scoped.WithLogger(logger)
+	}
+	return db
+}
\ No newline at end of file
@@ -0,0 +1,238 @@
+package streambot
+
+import(
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+/* The MemoryDatabase keeps channels and their subscription edges in process memory only. It is 
+ * meant for local development and tests, where no Rexster backend is at hand, and loses all data 
+ * when the process exits. Traversals behave like their Gremlin counterparts in GraphDatabase. */
+
+type MemoryDatabase struct {
+	lock			sync.RWMutex
+	channels		map[string]Channel
+	// Subscription edges by subscribing and subscribed channel uid, valued with creation time
+	subscriptions	map[string]map[string]int64
+	// Reverse index of subscription edges by subscribed and subscribing channel uid
+	subscribers		map[string]map[string]int64
+}
+
+func NewMemoryDatabase() *MemoryDatabase {
+	return &MemoryDatabase{
+		channels: make(map[string]Channel),
+		subscriptions: make(map[string]map[string]int64),
+		subscribers: make(map[string]map[string]int64),
+	}
+}
+
+func (db *MemoryDatabase) SaveChannel(ch *Channel) (err error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	db.channels[ch.Id] = *ch
+	return
+}
+
+func (db *MemoryDatabase) GetChannelWithUid(uid string) (err error, ch *Channel) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	stored, ok := db.channels[uid]
+	if !ok {
+		err = ErrChannelNotFound
+		return
+	}
+	ch = &stored
+	return
+}
+
+func (db *MemoryDatabase) UpdateChannel(uid string, version int64, patch ChannelPatch) (err error, ch *Channel) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	stored, ok := db.channels[uid]
+	if !ok {
+		err = ErrChannelNotFound
+		return
+	}
+	if !stored.HasVersion(version) {
+		err = ErrVersionMismatch
+		return
+	}
+	patch.Apply(&stored, time.Now().Unix())
+	db.channels[uid] = stored
+	ch = &stored
+	return
+}
+
+func (db *MemoryDatabase) ListChannels(
+	filter ChannelFilter, 
+	order ChannelSort, 
+	page Page,
+) (err error, chs []Channel) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	all := make([]Channel, 0, len(db.channels))
+	for _, ch := range db.channels {
+		all = append(all, ch)
+	}
+	chs = listChannels(all, filter, order, page)
+	return
+}
+
+func (db *MemoryDatabase) SaveChannelSubscription(
+	fromChannelId string, 
+	toChannelId string, 
+	creationTime int64,
+) (err error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	for _, uid := range []string{fromChannelId, toChannelId} {
+		if _, ok := db.channels[uid]; !ok {
+			errMsgFormat := "Unexpected error when saving Channel Subscription, unknown Channel `%s`"
+			err = WrapError(ErrChannelNotFound, fmt.Sprintf(errMsgFormat, uid))
+			return
+		}
+	}
+	if _, ok := db.subscriptions[fromChannelId][toChannelId]; ok {
+		return
+	}
+	addEdge(db.subscriptions, fromChannelId, toChannelId, creationTime)
+	addEdge(db.subscribers, toChannelId, fromChannelId, creationTime)
+	return
+}
+
+func (db *MemoryDatabase) DeleteChannelSubscription(fromChannelId string, toChannelId string) (err error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	removeEdge(db.subscriptions, fromChannelId, toChannelId)
+	removeEdge(db.subscribers, toChannelId, fromChannelId)
+	return
+}
+
+func (db *MemoryDatabase) GetSubscriptionsForChannelWithUid(
+	uid string, 
+	depth int,
+	page Page,
+) (err error, subs []Subscription) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	edgesFrom := func(from string) (err error, edges map[string]int64) {
+		return nil, db.subscriptions[from]
+	}
+	channelWithUid := func(uid string) (err error, ch Channel) {
+		return nil, db.channels[uid]
+	}
+	err, all := walkSubscriptions(uid, depth, edgesFrom, channelWithUid)
+	subs = pageOfSubscriptions(all, page)
+	return
+}
+
+func (db *MemoryDatabase) GetSubscribersForChannelWithUid(uid string) (err error, chs []Channel) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	chs = make([]Channel, 0, len(db.subscribers[uid]))
+	for _, from := range sortedKeys(db.subscribers[uid]) {
+		chs = append(chs, db.channels[from])
+	}
+	return
+}
+
+func (db *MemoryDatabase) DeleteChannel(uid string, version int64) (err error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	stored, ok := db.channels[uid]
+	if !ok {
+		err = ErrChannelNotFound
+		return
+	}
+	if !stored.HasVersion(version) {
+		err = ErrVersionMismatch
+		return
+	}
+	for to := range db.subscriptions[uid] {
+		removeEdge(db.subscribers, to, uid)
+	}
+	for from := range db.subscribers[uid] {
+		removeEdge(db.subscriptions, from, uid)
+	}
+	delete(db.subscriptions, uid)
+	delete(db.subscribers, uid)
+	delete(db.channels, uid)
+	return
+}
+
+func addEdge(edges map[string]map[string]int64, from string, to string, creationTime int64) {
+	if edges[from] == nil {
+		edges[from] = make(map[string]int64)
+	}
+	edges[from][to] = creationTime
+}
+
+func removeEdge(edges map[string]map[string]int64, from string, to string) {
+	delete(edges[from], to)
+	if len(edges[from]) == 0 {
+		delete(edges, from)
+	}
+}
+
+func sortedKeys(m map[string]int64) (keys []string) {
+	keys = make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return
+}
+
+// Walks the subscription edges from the channel with the given uid breadth first, so every 
+// channel is reached on a shortest path. The edges leaving a channel map the uids of the 
+// subscribed channels to the creation times of the subscriptions.
+func walkSubscriptions(
+	uid string, 
+	depth int, 
+	edgesFrom func(from string) (err error, edges map[string]int64), 
+	channelWithUid func(uid string) (err error, ch Channel),
+) (err error, all []Subscription) {
+	reached := map[string]bool{uid: true}
+	frontier := []string{uid}
+	all = make([]Subscription, 0)
+	for hops := 1; hops <= depth && len(frontier) > 0; hops++ {
+		var next []string
+		level := make([]Subscription, 0)
+		for _, from := range frontier {
+			err, edges := edgesFrom(from)
+			if err != nil {
+				return err, nil
+			}
+			for _, to := range sortedKeys(edges) {
+				if reached[to] {
+					continue
+				}
+				reached[to] = true
+				next = append(next, to)
+				err, ch := channelWithUid(to)
+				if err != nil {
+					return err, nil
+				}
+				level = append(level, Subscription{ch, edges[to], hops})
+			}
+		}
+		sort.Slice(level, func(i, j int) bool { return level[i].Channel.Id < level[j].Channel.Id })
+		all = append(all, level...)
+		frontier = next
+	}
+	return
+}
+
+// Cuts the given page out of a listing of subscriptions
+func pageOfSubscriptions(subs []Subscription, page Page) []Subscription {
+	if page.Offset >= len(subs) {
+		return []Subscription{}
+	}
+	subs = subs[page.Offset:]
+	if page.Limit > 0 && page.Limit < len(subs) {
+		subs = subs[:page.Limit]
+	}
+	return subs
+}
\ No newline at end of file
@@ -0,0 +1,55 @@
+package streambot
+
+import(
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Number of entries returned for a listing when the client does not ask for a limit
+const DefaultPageLimit = 100
+
+// Maximum number of entries a client can ask for in a single listing
+const MaxPageLimit = 1000
+
+// A window into a listing starting at the Offset-th entry and spanning at most Limit entries. A 
+// Limit of 0 stands for no limit.
+type Page struct {
+	Offset	int
+	Limit	int
+}
+
+const cursorPrefix = "o:"
+
+// Encodes the offset of the next page into an opaque cursor to be handed out to clients
+func EncodeCursor(offset int) string {
+	return base64.URLEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(offset)))
+}
+
+// Decodes the offset from a cursor that was created by EncodeCursor
+func DecodeCursor(cursor string) (offset int, err error) {
+	raw, err := base64.URLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		err = errors.New(fmt.Sprintf("Malformed cursor `%s`", cursor))
+		return
+	}
+	offset, err = strconv.Atoi(strings.TrimPrefix(string(raw), cursorPrefix))
+	if err != nil || offset < 0 {
+		err = errors.New(fmt.Sprintf("Malformed cursor `%s`", cursor))
+	}
+	return
+}
+
+// Fetches a page with fetch, asking for one more entry than the page spans to find out whether 
+// there is a next page at all. fetch reports the number of entries it got, of which the first 
+// keep entries make up the page. The cursor of the next page is empty for the last page.
+func FetchPage(page Page, fetch func(page Page) (err error, fetched int)) (err error, keep int, nextCursor string) {
+	err, keep = fetch(Page{Offset: page.Offset, Limit: page.Limit + 1})
+	if err == nil && keep > page.Limit {
+		keep = page.Limit
+		nextCursor = EncodeCursor(page.Offset + page.Limit)
+	}
+	return
+}
\ No newline at end of file
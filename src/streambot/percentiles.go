@@ -0,0 +1,106 @@
+package streambot
+
+import(
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+/* The PercentileSink is a MetricsSink summarizing the timings of every key and labels into the 
+ * 50th, 95th and 99th percentile over a window of time, for quick diagnosis straight from the 
+ * logs. Each window keeps a uniform random sample of at most MaxSamples timings per series, so 
+ * that memory stays bounded however many calls are made. */
+
+const DefaultPercentileMaxSamples = 1024
+
+type LatencySummary struct {
+	Key		string
+	Count	int
+	P50		time.Duration
+	P95		time.Duration
+	P99		time.Duration
+}
+
+type PercentileSink struct {
+	MaxSamples	int
+	lock		sync.Mutex
+	series		map[string]*latencySamples
+}
+
+type latencySamples struct {
+	samples	[]time.Duration
+	// Number of timings recorded in the window, of which the samples are taken
+	count	int
+}
+
+func NewPercentileSink() *PercentileSink {
+	return &PercentileSink{
+		MaxSamples: DefaultPercentileMaxSamples,
+		series: make(map[string]*latencySamples),
+	}
+}
+
+func (s *PercentileSink) Count(key string, labels []Label) {}
+
+func (s *PercentileSink) Size(key string, bytes int, labels []Label) {}
+
+func (s *PercentileSink) Time(key string, val time.Duration, labels []Label) {
+	key = flattenKey(key, labels)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	series, ok := s.series[key]
+	if !ok {
+		series = new(latencySamples)
+		s.series[key] = series
+	}
+	series.count++
+	if len(series.samples) < s.MaxSamples {
+		series.samples = append(series.samples, val)
+	} else if i := rand.Intn(series.count); i < s.MaxSamples {
+		// Keeps every timing of the window with the same probability
+		series.samples[i] = val
+	}
+}
+
+// Percentile of sorted samples by the nearest-rank method
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank - 1]
+}
+
+// Summarizes the timings of the current window by key and starts a new window
+func (s *PercentileSink) Summarize() []LatencySummary {
+	s.lock.Lock()
+	series := s.series
+	s.series = make(map[string]*latencySamples)
+	s.lock.Unlock()
+	summaries := make([]LatencySummary, 0, len(series))
+	for key, samples := range series {
+		sorted := samples.samples
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		summaries = append(summaries, LatencySummary{
+			key, 
+			samples.count, 
+			percentile(sorted, .5), 
+			percentile(sorted, .95), 
+			percentile(sorted, .99),
+		})
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Key < summaries[j].Key })
+	return summaries
+}
+
+// Logs the summaries of each window of the given length, never returns
+func (s *PercentileSink) LogEvery(interval time.Duration) {
+	for range time.Tick(interval) {
+		for _, summary := range s.Summarize() {
+			log.Info("Latency of %s over %d calls: p50 %v, p95 %v, p99 %v", summary.Key, 
+				summary.Count, summary.P50, summary.P95, summary.P99)
+		}
+	}
+}
\ No newline at end of file
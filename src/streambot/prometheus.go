@@ -0,0 +1,214 @@
+package streambot
+
+import(
+	"bytes"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* The PrometheusRegistry is a MetricsSink keeping all metrics in process memory to be scraped in 
+ * the Prometheus text format. Counted keys become counters named `<namespace>_<key>_total`, timed 
+ * keys become histograms named `<namespace>_<key>_duration_seconds` and sized keys histograms 
+ * named `<namespace>_<key>_bytes`, all labelled with the labels the metrics were recorded with. */
+
+// Upper bounds in seconds of the latency histogram buckets
+var DefaultLatencyBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Upper bounds in bytes of the size histogram buckets
+var DefaultSizeBuckets = []float64{100, 1000, 10000, 100000, 1000000, 10000000}
+
+type PrometheusRegistry struct {
+	Namespace	string
+	Buckets		[]float64
+	SizeBuckets	[]float64
+	lock		sync.Mutex
+	counters	map[string]map[string]*prometheusCounter
+	histograms	map[string]map[string]*prometheusHistogram
+}
+
+type prometheusCounter struct {
+	labels	[]Label
+	value	float64
+}
+
+type prometheusHistogram struct {
+	labels	[]Label
+	bounds	[]float64
+	// Count of observations per bucket, not accumulated over the lower buckets
+	buckets	[]uint64
+	count	uint64
+	sum		float64
+}
+
+func NewPrometheusRegistry(namespace string) *PrometheusRegistry {
+	return &PrometheusRegistry{
+		Namespace: namespace,
+		Buckets: DefaultLatencyBuckets,
+		SizeBuckets: DefaultSizeBuckets,
+		counters: make(map[string]map[string]*prometheusCounter),
+		histograms: make(map[string]map[string]*prometheusHistogram),
+	}
+}
+
+var prometheusNameReplacer = regexp.MustCompile("[^a-zA-Z0-9_]+")
+
+func (registry *PrometheusRegistry) metricName(key string, suffix string) string {
+	name := prometheusNameReplacer.ReplaceAllString(key, "_") + suffix
+	if registry.Namespace != "" {
+		name = registry.Namespace + "_" + name
+	}
+	return name
+}
+
+// Identifies a series of a metric by its label names and values
+func labelsKey(labels []Label) string {
+	var buf bytes.Buffer
+	for _, label := range labels {
+		fmt.Fprintf(&buf, "%s\x00%s\x00", label.Name, label.Value)
+	}
+	return buf.String()
+}
+
+func (registry *PrometheusRegistry) Count(key string, labels []Label) {
+	name := registry.metricName(key, "_total")
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	series, ok := registry.counters[name]
+	if !ok {
+		series = make(map[string]*prometheusCounter)
+		registry.counters[name] = series
+	}
+	id := labelsKey(labels)
+	counter, ok := series[id]
+	if !ok {
+		counter = &prometheusCounter{labels: append([]Label{}, labels...)}
+		series[id] = counter
+	}
+	counter.value++
+}
+
+func (registry *PrometheusRegistry) Time(key string, val time.Duration, labels []Label) {
+	name := registry.metricName(key, "_duration_seconds")
+	registry.observe(name, labels, val.Seconds(), registry.Buckets)
+}
+
+func (registry *PrometheusRegistry) Size(key string, bytes int, labels []Label) {
+	name := registry.metricName(key, "_bytes")
+	registry.observe(name, labels, float64(bytes), registry.SizeBuckets)
+}
+
+func (registry *PrometheusRegistry) observe(
+	name string, 
+	labels []Label, 
+	value float64, 
+	bounds []float64,
+) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	series, ok := registry.histograms[name]
+	if !ok {
+		series = make(map[string]*prometheusHistogram)
+		registry.histograms[name] = series
+	}
+	id := labelsKey(labels)
+	histogram, ok := series[id]
+	if !ok {
+		histogram = &prometheusHistogram{
+			labels: append([]Label{}, labels...), 
+			bounds: bounds,
+			buckets: make([]uint64, len(bounds)),
+		}
+		series[id] = histogram
+	}
+	if i := sort.SearchFloat64s(histogram.bounds, value); i < len(histogram.bounds) {
+		histogram.buckets[i]++
+	}
+	histogram.count++
+	histogram.sum += value
+}
+
+var prometheusLabelEscaper = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n")
+
+func formatPrometheusLabels(labels []Label, extra ...Label) string {
+	labels = append(append([]Label{}, labels...), extra...)
+	if len(labels) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(labels))
+	for i, label := range labels {
+		pairs[i] = fmt.Sprintf("%s=\"%s\"", label.Name, prometheusLabelEscaper.Replace(label.Value))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatPrometheusValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// Writes all metrics in the Prometheus text exposition format. Series are written in order of 
+// their labels, so that consecutive scrapes are stable.
+func (registry *PrometheusRegistry) Expose(buf *bytes.Buffer) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	names := make([]string, 0, len(registry.counters))
+	for name := range registry.counters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(buf, "# TYPE %s counter\n", name)
+		ids := make([]string, 0, len(registry.counters[name]))
+		for id := range registry.counters[name] {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			counter := registry.counters[name][id]
+			fmt.Fprintf(buf, "%s%s %s\n", name, formatPrometheusLabels(counter.labels), 
+				formatPrometheusValue(counter.value))
+		}
+	}
+	names = names[:0]
+	for name := range registry.histograms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(buf, "# TYPE %s histogram\n", name)
+		ids := make([]string, 0, len(registry.histograms[name]))
+		for id := range registry.histograms[name] {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			histogram := registry.histograms[name][id]
+			var cumulative uint64
+			for i, bound := range histogram.bounds {
+				cumulative += histogram.buckets[i]
+				le := Label{"le", formatPrometheusValue(bound)}
+				fmt.Fprintf(buf, "%s_bucket%s %d\n", name, formatPrometheusLabels(histogram.labels, le), 
+					cumulative)
+			}
+			le := Label{"le", "+Inf"}
+			fmt.Fprintf(buf, "%s_bucket%s %d\n", name, formatPrometheusLabels(histogram.labels, le), 
+				histogram.count)
+			fmt.Fprintf(buf, "%s_sum%s %s\n", name, formatPrometheusLabels(histogram.labels), 
+				formatPrometheusValue(histogram.sum))
+			fmt.Fprintf(buf, "%s_count%s %d\n", name, formatPrometheusLabels(histogram.labels), 
+				histogram.count)
+		}
+	}
+}
+
+func (registry *PrometheusRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	registry.Expose(&buf)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(buf.Bytes())
+}
\ No newline at end of file
@@ -0,0 +1,286 @@
+package streambot
+
+import(
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+/* Channels are found by the words in their name and description through an inverted index kept 
+ * in process memory. Words are lower cased runs of letters and digits. Every word of a query has 
+ * to match a word of a Channel, either exactly or as its prefix. Matches are ranked by how rare 
+ * the matched word is among all Channels, with matches in the name weighing more than matches in 
+ * the description and exact matches weighing more than prefix matches. The index is empty when 
+ * the process starts and has to be rebuilt from the Database. */
+
+const (
+	nameWeight = 3.0
+	descriptionWeight = 1.0
+	// Factor applied to the score of words only matched by prefix
+	prefixMatchWeight = 0.5
+)
+
+type SearchResult struct {
+	Channel	Channel
+	Score	float64
+}
+
+type SearchIndex struct {
+	lock		sync.RWMutex
+	channels	map[string]Channel
+	// Weight of every word per uid of the Channels containing it
+	postings	map[string]map[string]float64
+	// All indexed words in order, to look up words by prefix
+	words		[]string
+	// Uids of the Channels indexed or removed while a rebuild lists the Channels, nil otherwise
+	changed		map[string]bool
+	// Held for the whole of a rebuild, so rebuilds run one after another
+	rebuilding	sync.Mutex
+}
+
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{
+		channels: make(map[string]Channel),
+		postings: make(map[string]map[string]float64),
+	}
+}
+
+// Splits a text into lower cased words of letters and digits
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Adds the Channel to the index, replacing a previously indexed version of it
+func (index *SearchIndex) Index(ch Channel) {
+	index.lock.Lock()
+	defer index.lock.Unlock()
+	if index.changed != nil {
+		index.changed[ch.Id] = true
+	}
+	index.index(ch)
+}
+
+func (index *SearchIndex) index(ch Channel) {
+	index.remove(ch.Id)
+	index.channels[ch.Id] = ch
+	weights := make(map[string]float64)
+	for _, word := range Tokenize(ch.Name) {
+		weights[word] += nameWeight
+	}
+	for _, word := range Tokenize(ch.Description) {
+		weights[word] += descriptionWeight
+	}
+	for word, weight := range weights {
+		if index.postings[word] == nil {
+			index.postings[word] = make(map[string]float64)
+			i := sort.SearchStrings(index.words, word)
+			index.words = append(index.words, "")
+			copy(index.words[i + 1:], index.words[i:])
+			index.words[i] = word
+		}
+		index.postings[word][ch.Id] = weight
+	}
+}
+
+func (index *SearchIndex) Remove(uid string) {
+	index.lock.Lock()
+	defer index.lock.Unlock()
+	if index.changed != nil {
+		index.changed[uid] = true
+	}
+	index.remove(uid)
+}
+
+func (index *SearchIndex) remove(uid string) {
+	ch, ok := index.channels[uid]
+	if !ok {
+		return
+	}
+	delete(index.channels, uid)
+	for _, word := range append(Tokenize(ch.Name), Tokenize(ch.Description)...) {
+		postings, ok := index.postings[word]
+		if !ok {
+			continue
+		}
+		delete(postings, uid)
+		if len(postings) == 0 {
+			delete(index.postings, word)
+			i := sort.SearchStrings(index.words, word)
+			index.words = append(index.words[:i], index.words[i + 1:]...)
+		}
+	}
+}
+
+// Number of indexed Channels
+func (index *SearchIndex) Len() int {
+	index.lock.RLock()
+	defer index.lock.RUnlock()
+	return len(index.channels)
+}
+
+// Finds the Channels matching all words of the query, ordered by descending score and uid
+func (index *SearchIndex) Search(query string, page Page) []SearchResult {
+	words := Tokenize(query)
+	if len(words) == 0 {
+		return []SearchResult{}
+	}
+	index.lock.RLock()
+	defer index.lock.RUnlock()
+	var scores map[string]float64
+	for _, word := range words {
+		wordScores := index.score(word)
+		if scores == nil {
+			scores = wordScores
+			continue
+		}
+		// Only keep Channels that matched all words so far
+		for uid, score := range scores {
+			if wordScore, ok := wordScores[uid]; ok {
+				scores[uid] = score + wordScore
+			} else {
+				delete(scores, uid)
+			}
+		}
+	}
+	results := make([]SearchResult, 0, len(scores))
+	for uid, score := range scores {
+		results = append(results, SearchResult{index.channels[uid], score})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Channel.Id < results[j].Channel.Id
+	})
+	if page.Offset >= len(results) {
+		return []SearchResult{}
+	}
+	results = results[page.Offset:]
+	if page.Limit > 0 && page.Limit < len(results) {
+		results = results[:page.Limit]
+	}
+	return results
+}
+
+// Scores the Channels containing the word itself or a word it is the prefix of
+func (index *SearchIndex) score(word string) map[string]float64 {
+	scores := make(map[string]float64)
+	for i := sort.SearchStrings(index.words, word); i < len(index.words); i++ {
+		indexed := index.words[i]
+		if !strings.HasPrefix(indexed, word) {
+			break
+		}
+		postings := index.postings[indexed]
+		// Rare words tell more about a Channel than words most Channels contain
+		idf := math.Log(1 + float64(len(index.channels)) / float64(len(postings)))
+		if indexed != word {
+			idf *= prefixMatchWeight
+		}
+		for uid, weight := range postings {
+			// A Channel counts with the best of the words matching by prefix
+			scores[uid] = math.Max(scores[uid], weight * idf)
+		}
+	}
+	return scores
+}
+
+// Replaces the indexed Channels with all Channels listed by the Database. The Channels are listed 
+// at once, as backends like Rexster order all Channels again for every page. Channels indexed or 
+// removed while listing are newer than the listed ones and are kept as they are.
+func (index *SearchIndex) Rebuild(db Database) (err error) {
+	index.rebuilding.Lock()
+	defer index.rebuilding.Unlock()
+	index.lock.Lock()
+	index.changed = make(map[string]bool)
+	index.lock.Unlock()
+	err, chs := db.ListChannels(ChannelFilter{}, ChannelSort{}, Page{})
+	index.lock.Lock()
+	defer index.lock.Unlock()
+	changed := index.changed
+	index.changed = nil
+	if err != nil {
+		return WrapError(err, "Failed to list Channels to rebuild search index")
+	}
+	rebuilt := NewSearchIndex()
+	for _, ch := range chs {
+		if !changed[ch.Id] {
+			rebuilt.index(ch)
+		}
+	}
+	for uid := range changed {
+		if ch, ok := index.channels[uid]; ok {
+			rebuilt.index(ch)
+		}
+	}
+	index.channels, index.postings, index.words = rebuilt.channels, rebuilt.postings, rebuilt.words
+	return
+}
+
+// Rebuilds the index from the Database on POST requests and responds with the number of indexed 
+// Channels, to be served by the admin server
+func RebuildSearchIndexHandler(index *SearchIndex, db Database) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := index.Rebuild(db); err != nil {
+			log.Error("Unexpected error when rebuilding search index: %v", err)
+			http.Error(w, "Failed to rebuild search index", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int{"channels": index.Len()})
+	})
+}
+
+/* The SearchIndexedDatabase keeps a SearchIndex up to date with the Channels written to the 
+ * Database it decorates. */
+
+type SearchIndexedDatabase struct {
+	Database
+	Index *SearchIndex
+}
+
+func NewSearchIndexedDatabase(db Database, index *SearchIndex) *SearchIndexedDatabase {
+	return &SearchIndexedDatabase{db, index}
+}
+
+func (db *SearchIndexedDatabase) WithLogger(logger *Logger) Database {
+	return NewSearchIndexedDatabase(DatabaseWithLogger(db.Database, logger), db.Index)
+}
+
+func (db *SearchIndexedDatabase) SaveChannel(ch *Channel) (err error) {
+	err = db.Database.SaveChannel(ch)
+	if err == nil {
+		db.Index.Index(*ch)
+	}
+	return
+}
+
+func (db *SearchIndexedDatabase) UpdateChannel(
+	uid string, 
+	version int64, 
+	patch ChannelPatch,
+) (err error, ch *Channel) {
+	err, ch = db.Database.UpdateChannel(uid, version, patch)
+	if err == nil {
+		db.Index.Index(*ch)
+	}
+	return
+}
+
+func (db *SearchIndexedDatabase) DeleteChannel(uid string, version int64) (err error) {
+	err = db.Database.DeleteChannel(uid, version)
+	if err == nil {
+		db.Index.Remove(uid)
+	}
+	return
+}
\ No newline at end of file
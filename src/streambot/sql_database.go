@@ -0,0 +1,412 @@
+package streambot
+
+import(
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+/* The SQLDatabase keeps channels and subscriptions in a relational schema of two tables and 
+ * resolves transitive subscriptions with a recursive common table expression. Queries stick to 
+ * SQL understood by both PostgreSQL and SQLite, so any of their database/sql drivers can be used. 
+ * The schema is bootstrapped on startup by applying all migrations newer than the version 
+ * recorded in the `schema_migrations` table. */
+
+// Schema migrations in order of application, each one is applied exactly once per database
+var sqlMigrations = []string{
+	`CREATE TABLE channels (
+		uid VARCHAR(64) PRIMARY KEY,
+		name TEXT NOT NULL
+	)`,
+	`CREATE TABLE subscriptions (
+		from_uid VARCHAR(64) NOT NULL REFERENCES channels (uid),
+		to_uid VARCHAR(64) NOT NULL REFERENCES channels (uid),
+		created_at BIGINT NOT NULL,
+		PRIMARY KEY (from_uid, to_uid)
+	)`,
+	`CREATE INDEX subscriptions_to_uid ON subscriptions (to_uid)`,
+	// Channel metadata, Channels created before are left with empty values
+	`ALTER TABLE channels ADD COLUMN description TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE channels ADD COLUMN owner_id VARCHAR(64) NOT NULL DEFAULT ''`,
+	`ALTER TABLE channels ADD COLUMN created_at BIGINT NOT NULL DEFAULT 0`,
+	`ALTER TABLE channels ADD COLUMN updated_at BIGINT NOT NULL DEFAULT 0`,
+	// Channels created before versioning start at the initial version like new ones
+	`ALTER TABLE channels ADD COLUMN version BIGINT NOT NULL DEFAULT 1`,
+}
+
+// Columns of the channels table in the order scanned by channelFields
+const channelColumns = "c.uid, c.name, c.description, c.owner_id, c.created_at, c.updated_at, " +
+"c.version"
+
+func channelFields(ch *Channel) []interface{} {
+	return []interface{}{
+		&ch.Id, &ch.Name, &ch.Description, &ch.OwnerId, &ch.CreatedAt, &ch.UpdatedAt, &ch.Version,
+	}
+}
+
+type SQLDatabase struct {
+	DB *sql.DB
+}
+
+func NewSQLDatabase(driver string, dsn string) (db *SQLDatabase, err error) {
+	conn, err := sql.Open(driver, dsn)
+	if err == nil {
+		err = conn.Ping()
+	}
+	if err != nil {
+		errMsgFormat := "Unexpected error when opening `%s` database"
+		err = NewBackendUnavailableError(fmt.Sprintf(errMsgFormat, driver), err)
+		return
+	}
+	db = &SQLDatabase{conn}
+	err = db.Migrate()
+	if err != nil {
+		conn.Close()
+		db = nil
+	}
+	return
+}
+
+func (db *SQLDatabase) Close() error {
+	return db.DB.Close()
+}
+
+// Bootstraps the schema by applying all migrations which were not applied before
+func (db *SQLDatabase) Migrate() (err error) {
+	_, err = db.DB.Exec("CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL)")
+	if err != nil {
+		err = sqlError("Unexpected error when creating schema migrations table", err)
+		return
+	}
+	tx, err := db.DB.Begin()
+	if err != nil {
+		err = sqlError("Unexpected error when starting schema migration", err)
+		return
+	}
+	defer tx.Rollback()
+	var version int
+	err = tx.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	if err != nil {
+		err = sqlError("Unexpected error when reading schema version", err)
+		return
+	}
+	for ; version < len(sqlMigrations); version++ {
+		_, err = tx.Exec(sqlMigrations[version])
+		if err == nil {
+			_, err = tx.Exec("INSERT INTO schema_migrations (version) VALUES ($1)", version + 1)
+		}
+		if err != nil {
+			errMsgFormat := "Unexpected error when migrating schema to version %d"
+			err = sqlError(fmt.Sprintf(errMsgFormat, version + 1), err)
+			return
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		err = sqlError("Unexpected error when committing schema migration", err)
+	}
+	return
+}
+
+func (db *SQLDatabase) SaveChannel(ch *Channel) (err error) {
+	query := "INSERT INTO channels (uid, name, description, owner_id, created_at, updated_at, " +
+	"version) VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (uid) DO UPDATE SET " +
+	"name = excluded.name, description = excluded.description, owner_id = excluded.owner_id, " +
+	"created_at = excluded.created_at, updated_at = excluded.updated_at, version = excluded.version"
+	_, err = db.DB.Exec(
+		query, ch.Id, ch.Name, ch.Description, ch.OwnerId, ch.CreatedAt, ch.UpdatedAt, ch.Version,
+	)
+	if err != nil {
+		err = sqlError(fmt.Sprintf("Unexpected error when saving Channel `%v`", ch), err)
+	}
+	return
+}
+
+func (db *SQLDatabase) GetChannelWithUid(uid string) (err error, ch *Channel) {
+	ch = new(Channel)
+	query := "SELECT " + channelColumns + " FROM channels c WHERE c.uid = $1"
+	err = db.DB.QueryRow(query, uid).Scan(channelFields(ch)...)
+	if err == sql.ErrNoRows {
+		err, ch = ErrChannelNotFound, nil
+	} else if err != nil {
+		errMsgFormat := "Unexpected error when fetching Channel with Id `%s`"
+		err, ch = sqlError(fmt.Sprintf(errMsgFormat, uid), err), nil
+	}
+	return
+}
+
+func (db *SQLDatabase) UpdateChannel(uid string, version int64, patch ChannelPatch) (err error, ch *Channel) {
+	return retryUnconditionalUpdate(version, func() (error, *Channel) {
+		return db.updateChannel(uid, version, patch)
+	})
+}
+
+func (db *SQLDatabase) updateChannel(uid string, version int64, patch ChannelPatch) (err error, ch *Channel) {
+	errMsg := fmt.Sprintf("Unexpected error when updating Channel with Id `%s`", uid)
+	tx, err := db.DB.Begin()
+	if err != nil {
+		err = sqlError(errMsg, err)
+		return
+	}
+	defer tx.Rollback()
+	ch = new(Channel)
+	query := "SELECT " + channelColumns + " FROM channels c WHERE c.uid = $1"
+	err = tx.QueryRow(query, uid).Scan(channelFields(ch)...)
+	if err == sql.ErrNoRows {
+		err, ch = ErrChannelNotFound, nil
+		return
+	}
+	if err == nil && !ch.HasVersion(version) {
+		err, ch = ErrVersionMismatch, nil
+		return
+	}
+	var updated int64
+	if err == nil {
+		// Only write when no concurrent transaction updated the Channel since it was read
+		current := ch.Version
+		patch.Apply(ch, time.Now().Unix())
+		query = "UPDATE channels SET name = $1, description = $2, owner_id = $3, updated_at = $4, " +
+		"version = $5 WHERE uid = $6 AND version = $7"
+		var res sql.Result
+		res, err = tx.Exec(query, ch.Name, ch.Description, ch.OwnerId, ch.UpdatedAt, ch.Version, uid, current)
+		if err == nil {
+			updated, err = res.RowsAffected()
+		}
+	}
+	if err == nil && updated == 0 {
+		err, ch = ErrVersionMismatch, nil
+		return
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		err, ch = sqlError(errMsg, err), nil
+	}
+	return
+}
+
+func (db *SQLDatabase) ListChannels(
+	filter ChannelFilter, 
+	order ChannelSort, 
+	page Page,
+) (err error, chs []Channel) {
+	// Only known columns are formatted into the query as sort field
+	field := order.SortField()
+	if field != SortChannelsByName && field != SortChannelsByCreatedAt {
+		err = errors.New(fmt.Sprintf("Cannot sort Channels by `%s`", field))
+		return
+	}
+	direction := "ASC"
+	if order.Descending {
+		direction = "DESC"
+	}
+	query := fmt.Sprintf("SELECT %s FROM channels c WHERE LOWER(c.name) LIKE $1 ESCAPE '\\' " +
+	"ORDER BY c.%s %s, c.uid LIMIT $2 OFFSET $3", channelColumns, field, direction)
+	limit := page.Limit
+	if limit <= 0 {
+		limit = math.MaxInt32
+	}
+	prefix := likeEscaper.Replace(strings.ToLower(filter.NamePrefix)) + "%"
+	rows, err := db.DB.Query(query, prefix, limit, page.Offset)
+	if err != nil {
+		err = sqlError("Failed to list channels", err)
+		return
+	}
+	defer rows.Close()
+	chs = make([]Channel, 0)
+	for rows.Next() {
+		var ch Channel
+		if err = rows.Scan(channelFields(&ch)...); err != nil {
+			err = sqlError("Failed to read channel", err)
+			return
+		}
+		chs = append(chs, ch)
+	}
+	if err = rows.Err(); err != nil {
+		err = sqlError("Failed to list channels", err)
+	}
+	return
+}
+
+// Escapes the wildcards of LIKE patterns
+var likeEscaper = strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_")
+
+func (db *SQLDatabase) SaveChannelSubscription(
+	fromChannelId string, 
+	toChannelId string, 
+	creationTime int64,
+) (err error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		err = sqlError("Unexpected error when saving Channel Subscription", err)
+		return
+	}
+	defer tx.Rollback()
+	for _, uid := range []string{fromChannelId, toChannelId} {
+		var found int
+		err = tx.QueryRow("SELECT COUNT(*) FROM channels WHERE uid = $1", uid).Scan(&found)
+		if err == nil && found == 0 {
+			errMsgFormat := "Unexpected error when saving Channel Subscription, unknown Channel `%s`"
+			err = WrapError(ErrChannelNotFound, fmt.Sprintf(errMsgFormat, uid))
+			return
+		}
+		if err != nil {
+			err = sqlError("Unexpected error when saving Channel Subscription", err)
+			return
+		}
+	}
+	query := "INSERT INTO subscriptions (from_uid, to_uid, created_at) VALUES ($1, $2, $3) " +
+	"ON CONFLICT (from_uid, to_uid) DO NOTHING"
+	_, err = tx.Exec(query, fromChannelId, toChannelId, creationTime)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		err = sqlError("Unexpected error when saving Channel Subscription", err)
+	}
+	return
+}
+
+func (db *SQLDatabase) DeleteChannelSubscription(fromChannelId string, toChannelId string) (err error) {
+	query := "DELETE FROM subscriptions WHERE from_uid = $1 AND to_uid = $2"
+	_, err = db.DB.Exec(query, fromChannelId, toChannelId)
+	if err != nil {
+		err = sqlError("Unexpected error when deleting Channel Subscription", err)
+	}
+	return
+}
+
+func (db *SQLDatabase) GetSubscriptionsForChannelWithUid(
+	uid string, 
+	depth int,
+	page Page,
+) (err error, subs []Subscription) {
+	// Follow the subscriptions up to the given depth and report every reached channel other than 
+	// the subscribing one with its shortest distance and the creation time of the edge leading 
+	// to it on that distance
+	query := `WITH RECURSIVE reached (uid, hops, created_at) AS (
+		SELECT to_uid, 1, created_at FROM subscriptions WHERE from_uid = $1
+		UNION ALL
+		SELECT s.to_uid, r.hops + 1, s.created_at 
+		FROM subscriptions s JOIN reached r ON s.from_uid = r.uid 
+		WHERE r.hops < $2
+	), shortest (uid, hops) AS (
+		SELECT uid, MIN(hops) FROM reached WHERE uid <> $1 GROUP BY uid
+	)
+	SELECT ` + channelColumns + `, MIN(r.created_at), s.hops 
+	FROM shortest s 
+	JOIN reached r ON r.uid = s.uid AND r.hops = s.hops 
+	JOIN channels c ON c.uid = s.uid 
+	GROUP BY ` + channelColumns + `, s.hops 
+	ORDER BY s.hops, c.uid 
+	LIMIT $3 OFFSET $4`
+	limit := page.Limit
+	if limit <= 0 {
+		limit = math.MaxInt32
+	}
+	rows, err := db.DB.Query(query, uid, depth, limit, page.Offset)
+	if err != nil {
+		err = sqlError("Failed to query subscribed channels", err)
+		return
+	}
+	defer rows.Close()
+	subs = make([]Subscription, 0)
+	for rows.Next() {
+		var sub Subscription
+		err = rows.Scan(append(channelFields(&sub.Channel), &sub.CreatedAt, &sub.Hops)...)
+		if err != nil {
+			err = sqlError("Failed to read subscribed channel", err)
+			return
+		}
+		subs = append(subs, sub)
+	}
+	if err = rows.Err(); err != nil {
+		err = sqlError("Failed to query subscribed channels", err)
+	}
+	return
+}
+
+func (db *SQLDatabase) GetSubscribersForChannelWithUid(uid string) (err error, chs []Channel) {
+	query := "SELECT " + channelColumns + " FROM subscriptions s JOIN channels c " +
+	"ON c.uid = s.from_uid WHERE s.to_uid = $1 ORDER BY c.uid"
+	rows, err := db.DB.Query(query, uid)
+	if err != nil {
+		err = sqlError("Failed to query subscribing channels", err)
+		return
+	}
+	defer rows.Close()
+	chs = make([]Channel, 0)
+	for rows.Next() {
+		var ch Channel
+		if err = rows.Scan(channelFields(&ch)...); err != nil {
+			err = sqlError("Failed to read subscribing channel", err)
+			return
+		}
+		chs = append(chs, ch)
+	}
+	if err = rows.Err(); err != nil {
+		err = sqlError("Failed to query subscribing channels", err)
+	}
+	return
+}
+
+func (db *SQLDatabase) DeleteChannel(uid string, version int64) (err error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		err = sqlError(fmt.Sprintf("Unexpected error when deleting Channel with Id `%s`", uid), err)
+		return
+	}
+	defer tx.Rollback()
+	var current int64
+	err = tx.QueryRow("SELECT version FROM channels WHERE uid = $1", uid).Scan(&current)
+	if err == sql.ErrNoRows {
+		err = ErrChannelNotFound
+		return
+	}
+	if err == nil && version != 0 && current != version {
+		err = ErrVersionMismatch
+		return
+	}
+	if err != nil {
+		err = sqlError(fmt.Sprintf("Unexpected error when deleting Channel with Id `%s`", uid), err)
+		return
+	}
+	_, err = tx.Exec("DELETE FROM subscriptions WHERE from_uid = $1 OR to_uid = $1", uid)
+	if err != nil {
+		err = sqlError(fmt.Sprintf("Unexpected error when deleting Channel with Id `%s`", uid), err)
+		return
+	}
+	res, err := tx.Exec("DELETE FROM channels WHERE uid = $1 AND ($2 = 0 OR version = $2)", uid, version)
+	var deleted int64
+	if err == nil {
+		deleted, err = res.RowsAffected()
+	}
+	if err == nil && deleted == 0 {
+		// The Channel was deleted or, if a version was asked for, changed by a concurrent transaction
+		err = ErrChannelNotFound
+		if version != 0 {
+			err = ErrVersionMismatch
+		}
+		return
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		err = sqlError(fmt.Sprintf("Unexpected error when deleting Channel with Id `%s`", uid), err)
+	}
+	return
+}
+
+// Wraps an error of the SQL driver, telling lost connections apart from failed statements
+func sqlError(message string, err error) error {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) {
+		return NewBackendUnavailableError(message, err)
+	}
+	return errors.New(fmt.Sprintf("%s: %v", message, err))
+}
\ No newline at end of file
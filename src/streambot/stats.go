@@ -1,46 +1,324 @@
 package streambot
 
 import(
+	"bytes"
 	"net"
 	"errors"
 	"fmt"
+	"io"
+	"math/rand"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+/* Metrics are recorded through Stats, usually the Statter handing them to any number of sinks. 
+ * A metric is identified by its key and optional labels, such as the database method of a call 
+ * or the status code of a response. Sinks without a notion of labels, like StatsD, append the 
+ * label values to the key. Recording metrics never blocks or fails, so that request handling 
+ * does not depend on the metrics path. */
+
+type Stats interface {
+	Count(key string, labels ...Label)
+	Time(key string, val time.Duration, labels ...Label)
+	// Records a size in bytes
+	Size(key string, bytes int, labels ...Label)
+}
+
+// Stats used when metrics are disabled or no sink is available
+type NoopStats struct {}
+
+func(NoopStats) Count(key string, labels ...Label) {}
+
+func(NoopStats) Time(key string, val time.Duration, labels ...Label) {}
+
+func(NoopStats) Size(key string, bytes int, labels ...Label) {}
+
+type Label struct {
+	Name	string
+	Value	string
+}
+
+type MetricsSink interface {
+	Count(key string, labels []Label)
+	Time(key string, val time.Duration, labels []Label)
+	Size(key string, bytes int, labels []Label)
+}
+
 type Statter struct {
-	StatConn net.Conn
-	Prefix 	 string
+	Sinks []MetricsSink
 }
 
-func NewLocalStatsDStatter() (s *Statter, err error) {
-	conn, err := net.Dial("udp", ":8125")
+func NewStatter(sinks ...MetricsSink) *Statter {
+	return &Statter{sinks}
+}
+
+func(s *Statter) Count(key string, labels ...Label) {
+	if s == nil || key == "" {
+		return
+	}
+	for _, sink := range s.Sinks {
+		sink.Count(key, labels)
+	}
+}
+
+func(s *Statter) Time(key string, val time.Duration, labels ...Label) {
+	if s == nil || key == "" {
+		return
+	}
+	for _, sink := range s.Sinks {
+		sink.Time(key, val, labels)
+	}
+}
+
+func(s *Statter) Size(key string, bytes int, labels ...Label) {
+	if s == nil || key == "" {
+		return
+	}
+	for _, sink := range s.Sinks {
+		sink.Size(key, bytes, labels)
+	}
+}
+
+// Flushes and closes all sinks that need to be closed
+func(s *Statter) Close() {
+	if s == nil {
+		return
+	}
+	for _, sink := range s.Sinks {
+		if closer, ok := sink.(io.Closer); ok {
+			closer.Close()
+		}
+	}
+}
+
+const (
+	DefaultStatsDPort = 8125
+	// Metrics waiting to be sent, further metrics are dropped while the buffer is full
+	DefaultStatsDBufferSize = 1000
+	// Fits into a single ethernet frame along with the IP and UDP headers
+	DefaultStatsDMaxPacketSize = 1432
+	DefaultStatsDFlushInterval = 100 * time.Millisecond
+)
+
+type StatterOptions struct {
+	// Host and port of the StatsD daemon, the local host and DefaultStatsDPort when empty
+	Host		string
+	Port		int
+	// Prepended to all metric keys, the host name when empty
+	Prefix		string
+	// Fraction of metrics to send per metric key, keys without a sample rate fall back to 
+	// SampleRate and are all sent if that is 0 too
+	SampleRate	float64
+	SampleRates	map[string]float64
+	// Batching of metrics into packets, defaults to the DefaultStatsD constants when 0
+	BufferSize		int
+	MaxPacketSize	int
+	FlushInterval	time.Duration
+}
+
+/* The StatsDSink hands metrics to a goroutine through a buffered channel and drops them when the 
+ * buffer is full, instead of waiting for it. The goroutine coalesces the metrics into packets of 
+ * up to MaxPacketSize bytes, which are sent once full or when FlushInterval has passed. */
+
+type StatsDSink struct {
+	StatConn		net.Conn
+	Prefix			string
+	SampleRate		float64
+	SampleRates		map[string]float64
+	MaxPacketSize	int
+	FlushInterval	time.Duration
+	lines			chan string
+	done			chan bool
+	dropped			uint64
+	// Guards closing lines against concurrent sends
+	lock			sync.RWMutex
+	closed			bool
+}
+
+func NewStatsDSink(options StatterOptions) (s *StatsDSink, err error) {
+	port := options.Port
+	if port == 0 {
+		port = DefaultStatsDPort
+	}
+	conn, err := net.Dial("udp", net.JoinHostPort(options.Host, strconv.Itoa(port)))
 	if err != nil {
 		err = errors.New(fmt.Sprintf("Statter Error when instantiate UDP statting connection: %v", err))
 		return
 	}
-	host, err := os.Hostname()
+	s, err = NewStatsDSinkWithConn(conn, options)
 	if err != nil {
-		err = errors.New(fmt.Sprintf("Statter Error when retrieving host name: %v", err))
-		return
+		conn.Close()
 	}
-	host = strings.Replace(host, ".", "-", -1)
-	s = &Statter{conn, host + "."}
 	return
 }
 
-func(s *Statter) Count(key string) {
-	if key == "" {
-		return
+// Sink sending metrics over the given connection, ignoring the host and port of the options
+func NewStatsDSinkWithConn(conn net.Conn, options StatterOptions) (s *StatsDSink, err error) {
+	prefix := options.Prefix
+	if prefix == "" {
+		prefix, err = os.Hostname()
+		if err != nil {
+			err = errors.New(fmt.Sprintf("Statter Error when retrieving host name: %v", err))
+			return
+		}
+		prefix = strings.Replace(prefix, ".", "-", -1)
+	}
+	if !strings.HasSuffix(prefix, ".") {
+		prefix += "."
+	}
+	s = &StatsDSink{
+		StatConn: conn,
+		Prefix: prefix,
+		SampleRate: options.SampleRate,
+		SampleRates: options.SampleRates,
+		MaxPacketSize: options.MaxPacketSize,
+		FlushInterval: options.FlushInterval,
+		done: make(chan bool),
+	}
+	if s.MaxPacketSize <= 0 {
+		s.MaxPacketSize = DefaultStatsDMaxPacketSize
+	}
+	if s.FlushInterval <= 0 {
+		s.FlushInterval = DefaultStatsDFlushInterval
+	}
+	bufferSize := options.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = DefaultStatsDBufferSize
 	}
-	fmt.Fprintln(s.StatConn, fmt.Sprintf("%s%s:1|c", s.Prefix, key))
+	s.lines = make(chan string, bufferSize)
+	go s.flush()
 	return
 }
 
-func(s *Statter) Time(key string, val int) {
-	if key == "" {
+// Collects metrics into packets until the sink is closed
+func(s *StatsDSink) flush() {
+	ticker := time.NewTicker(s.FlushInterval)
+	defer ticker.Stop()
+	var packet bytes.Buffer
+	for {
+		select {
+		case line, ok := <- s.lines:
+			if !ok {
+				s.write(&packet)
+				close(s.done)
+				return
+			}
+			if packet.Len() > 0 && packet.Len() + len(line) > s.MaxPacketSize {
+				s.write(&packet)
+			}
+			packet.WriteString(line)
+		case <- ticker.C:
+			s.write(&packet)
+		}
+	}
+}
+
+// Sends the packet, metrics not received by StatsD are lost anyway
+func(s *StatsDSink) write(packet *bytes.Buffer) {
+	if packet.Len() == 0 {
 		return
 	}
-	fmt.Fprintln(s.StatConn, fmt.Sprintf("%s%s:%d|ms", s.Prefix, key, val))
+	s.StatConn.Write(packet.Bytes())
+	packet.Reset()
+}
+
+// Number of metrics dropped as the buffer was full or the sink was closed
+func(s *StatsDSink) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Sends the remaining metrics, metrics recorded afterwards by requests still in flight are dropped. 
+// Closing again does nothing.
+func(s *StatsDSink) Close() error {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.lines)
+	s.lock.Unlock()
+	<- s.done
+	return s.StatConn.Close()
+}
+
+// Statter sending to StatsD only
+func NewStatsDStatter(options StatterOptions) (s *Statter, err error) {
+	sink, err := NewStatsDSink(options)
+	if err != nil {
+		return
+	}
+	s = NewStatter(sink)
 	return
+}
+
+func NewLocalStatsDStatter() (s *Statter, err error) {
+	return NewStatsDStatter(StatterOptions{})
+}
+
+// Appends the label values to the key, replacing characters StatsD treats as separators
+func flattenKey(key string, labels []Label) string {
+	for _, label := range labels {
+		key += "." + strings.Trim(flattenKeyReplacer.ReplaceAllString(label.Value, "_"), "_")
+	}
+	return key
+}
+
+var flattenKeyReplacer = regexp.MustCompile("[^a-zA-Z0-9_-]+")
+
+// Sample rate of the metric key without labels, 1 if all of its metrics are sent
+func(s *StatsDSink) sampleRate(key string) float64 {
+	rate, ok := s.SampleRates[key]
+	if !ok {
+		rate = s.SampleRate
+	}
+	if rate <= 0 || rate > 1 {
+		return 1
+	}
+	return rate
+}
+
+// Sends the metric unless it is sampled out. Sampled metrics tell StatsD their rate, so that it 
+// scales them back up. The rate is looked up by the key before the labels are appended.
+func(s *StatsDSink) send(key string, labels []Label, value string) {
+	rate := s.sampleRate(key)
+	key = flattenKey(key, labels)
+	line := fmt.Sprintf("%s%s:%s\n", s.Prefix, key, value)
+	if rate < 1 {
+		if rand.Float64() >= rate {
+			return
+		}
+		line = fmt.Sprintf("%s%s:%s|@%s\n", s.Prefix, key, value, strconv.FormatFloat(rate, 'f', -1, 64))
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.closed {
+		atomic.AddUint64(&s.dropped, 1)
+		return
+	}
+	select {
+	case s.lines <- line:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+	}
+}
+
+func(s *StatsDSink) Count(key string, labels []Label) {
+	s.send(key, labels, "1|c")
+}
+
+// Durations are sent in milliseconds with microsecond precision
+func(s *StatsDSink) Time(key string, val time.Duration, labels []Label) {
+	millis := float64(val.Round(time.Microsecond)) / float64(time.Millisecond)
+	s.send(key, labels, strconv.FormatFloat(millis, 'f', -1, 64) + "|ms")
+}
+
+// Sizes are sent as histograms, which StatsD aggregates like timings
+func(s *StatsDSink) Size(key string, bytes int, labels []Label) {
+	s.send(key, labels, fmt.Sprintf("%d|h", bytes))
 }
\ No newline at end of file
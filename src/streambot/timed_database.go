@@ -0,0 +1,122 @@
+package streambot
+
+import(
+	"time"
+)
+
+/* The TimedDatabase records the duration of every call to the Database it decorates, labelled by 
+ * method, whether the call succeeded or not. Failed calls are counted separately as well. All 
+ * methods are forwarded explicitly rather than through embedding, so that a method added to the 
+ * Database interface cannot go untimed. */
+
+type TimedDatabase struct {
+	Database	Database
+	Stats		Stats
+	Logger		*Logger
+}
+
+func NewTimedDatabase(db Database, stats Stats) *TimedDatabase {
+	if stats == nil {
+		stats = NoopStats{}
+	}
+	return &TimedDatabase{db, stats, nil}
+}
+
+func (db *TimedDatabase) WithLogger(logger *Logger) Database {
+	return &TimedDatabase{DatabaseWithLogger(db.Database, logger), db.Stats, logger}
+}
+
+func (db *TimedDatabase) track(method string, begin time.Time, err error) {
+	duration := time.Since(begin)
+	logger := db.Logger.With(Fields{
+		"method": method, 
+		"duration_ms": float64(duration) / float64(time.Millisecond),
+	})
+	if err != nil {
+		logger = logger.WithError(err)
+	}
+	logger.Debug("Database call %s took %v", method, duration)
+	db.Stats.Time("db", duration, Label{"method", method})
+	if err != nil {
+		db.Stats.Count("db.errors", Label{"method", method})
+	}
+}
+
+func (db *TimedDatabase) SaveChannel(ch *Channel) (err error) {
+	begin := time.Now()
+	err = db.Database.SaveChannel(ch)
+	db.track("SaveChannel", begin, err)
+	return
+}
+
+func (db *TimedDatabase) GetChannelWithUid(uid string) (err error, ch *Channel) {
+	begin := time.Now()
+	err, ch = db.Database.GetChannelWithUid(uid)
+	db.track("GetChannelWithUid", begin, err)
+	return
+}
+
+func (db *TimedDatabase) UpdateChannel(
+	uid string, 
+	version int64, 
+	patch ChannelPatch,
+) (err error, ch *Channel) {
+	begin := time.Now()
+	err, ch = db.Database.UpdateChannel(uid, version, patch)
+	db.track("UpdateChannel", begin, err)
+	return
+}
+
+func (db *TimedDatabase) ListChannels(
+	filter ChannelFilter, 
+	order ChannelSort, 
+	page Page,
+) (err error, chs []Channel) {
+	begin := time.Now()
+	err, chs = db.Database.ListChannels(filter, order, page)
+	db.track("ListChannels", begin, err)
+	return
+}
+
+func (db *TimedDatabase) SaveChannelSubscription(
+	fromChannelId string, 
+	toChannelId string, 
+	creationTime int64,
+) (err error) {
+	begin := time.Now()
+	err = db.Database.SaveChannelSubscription(fromChannelId, toChannelId, creationTime)
+	db.track("SaveChannelSubscription", begin, err)
+	return
+}
+
+func (db *TimedDatabase) GetSubscriptionsForChannelWithUid(
+	uid string, 
+	depth int, 
+	page Page,
+) (err error, subs []Subscription) {
+	begin := time.Now()
+	err, subs = db.Database.GetSubscriptionsForChannelWithUid(uid, depth, page)
+	db.track("GetSubscriptionsForChannelWithUid", begin, err)
+	return
+}
+
+func (db *TimedDatabase) GetSubscribersForChannelWithUid(uid string) (err error, chs []Channel) {
+	begin := time.Now()
+	err, chs = db.Database.GetSubscribersForChannelWithUid(uid)
+	db.track("GetSubscribersForChannelWithUid", begin, err)
+	return
+}
+
+func (db *TimedDatabase) DeleteChannel(uid string, version int64) (err error) {
+	begin := time.Now()
+	err = db.Database.DeleteChannel(uid, version)
+	db.track("DeleteChannel", begin, err)
+	return
+}
+
+func (db *TimedDatabase) DeleteChannelSubscription(fromChannelId string, toChannelId string) (err error) {
+	begin := time.Now()
+	err = db.Database.DeleteChannelSubscription(fromChannelId, toChannelId)
+	db.track("DeleteChannelSubscription", begin, err)
+	return
+}
\ No newline at end of file
@@ -0,0 +1,111 @@
+package streambot
+
+import(
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+/* Request payloads are validated before anything is passed on to the Database. All violated 
+ * rules are collected per field and reported together, so clients can fix a request at once. */
+
+const (
+	MaxChannelNameLength = 100
+	MaxChannelDescriptionLength = 1000
+	MaxChannelIdLength = 64
+)
+
+// Channel ids are generated as UUIDs, other ids are only accepted for data migrated from elsewhere
+var channelIdPattern = regexp.MustCompile("^[A-Za-z0-9_-]+$")
+
+type FieldError struct {
+	Field		string	`json:"field"`
+	Message		string	`json:"message"`
+}
+
+// Error reporting all fields of a request that failed validation
+func NewValidationError(fields []FieldError) *Error {
+	e := NewError(ErrorCodeValidationFailed, "Request failed validation", nil)
+	e.Details = fields
+	return e
+}
+
+func ValidateChannelName(field string, name string) (errs []FieldError) {
+	if strings.TrimSpace(name) == "" {
+		return append(errs, FieldError{field, "is required"})
+	}
+	if !utf8.ValidString(name) {
+		return append(errs, FieldError{field, "must be valid UTF-8"})
+	}
+	if utf8.RuneCountInString(name) > MaxChannelNameLength {
+		errMsg := fmt.Sprintf("must not be longer than %d characters", MaxChannelNameLength)
+		errs = append(errs, FieldError{field, errMsg})
+	}
+	if strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		errs = append(errs, FieldError{field, "must not contain control characters"})
+	}
+	return
+}
+
+func ValidateChannelId(field string, id string) (errs []FieldError) {
+	if id == "" {
+		return append(errs, FieldError{field, "is required"})
+	}
+	if len(id) > MaxChannelIdLength {
+		errMsg := fmt.Sprintf("must not be longer than %d characters", MaxChannelIdLength)
+		errs = append(errs, FieldError{field, errMsg})
+	}
+	if !channelIdPattern.MatchString(id) {
+		errs = append(errs, FieldError{field, "must only contain letters, digits, `-` and `_`"})
+	}
+	return
+}
+
+func ValidateChannelDescription(field string, description string) (errs []FieldError) {
+	if utf8.RuneCountInString(description) > MaxChannelDescriptionLength {
+		errMsg := fmt.Sprintf("must not be longer than %d characters", MaxChannelDescriptionLength)
+		errs = append(errs, FieldError{field, errMsg})
+	}
+	return
+}
+
+// Owners are optional, if given their ids follow the same rules as Channel ids
+func ValidateOwnerId(field string, id string) (errs []FieldError) {
+	if id != "" {
+		errs = ValidateChannelId(field, id)
+	}
+	return
+}
+
+func (in PutChannelInData) Validate() (errs []FieldError) {
+	errs = ValidateChannelName("name", in.Name)
+	errs = append(errs, ValidateChannelDescription("description", in.Description)...)
+	return append(errs, ValidateOwnerId("owner_id", in.OwnerId)...)
+}
+
+func (patch ChannelPatch) Validate() (errs []FieldError) {
+	if patch.Name != nil {
+		errs = ValidateChannelName("name", *patch.Name)
+	}
+	if patch.Description != nil {
+		errs = append(errs, ValidateChannelDescription("description", *patch.Description)...)
+	}
+	if patch.OwnerId != nil {
+		errs = append(errs, ValidateOwnerId("owner_id", *patch.OwnerId)...)
+	}
+	return
+}
+
+// Validates a subscription of the Channel with given id, which cannot subscribe to itself
+func (in PostChannelSubscriptionsInData) Validate(fromChannelId string) (errs []FieldError) {
+	errs = ValidateChannelId("channel_id", in.ToChannelId)
+	if len(errs) == 0 && in.ToChannelId == fromChannelId {
+		errs = append(errs, FieldError{"channel_id", "must not be the subscribing Channel itself"})
+	}
+	if in.Time < 0 {
+		errs = append(errs, FieldError{"created_at", "must not be negative"})
+	}
+	return
+}
\ No newline at end of file
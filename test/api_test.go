@@ -11,6 +11,10 @@ import (
 	"fmt"
 	"code.google.com/p/go-uuid/uuid"
 	"time"
+	"os"
+	"strings"
+	"net/http/httptest"
+	"github.com/boltdb/bolt"
 )
 
 
@@ -32,7 +36,18 @@ type PostNewChannelResponse struct {
 type DatabaseMock struct {
 	SavedChannel 			streambot.Channel
 	SavedSubscription		TestChannelSubscriptionData
-	ChannelSubscriptions 	[]streambot.Channel
+	ChannelSubscriptions 	[]streambot.Subscription
+	DeletedChannelId		string
+	MissingChannelId		string
+	DeletedSubscription		TestChannelSubscriptionData
+	ChannelSubscribers		[]streambot.Channel
+	SubscriptionsDepth		int
+	SubscriptionsPage		streambot.Page
+	UpdatedChannel			streambot.Channel
+	ChannelVersion			int64
+	ListedChannels			[]streambot.Channel
+	ListFilter				streambot.ChannelFilter
+	ListSort				streambot.ChannelSort
 }
 
 func(db *DatabaseMock) SaveChannel(ch *streambot.Channel) (err error) {
@@ -41,7 +56,48 @@ func(db *DatabaseMock) SaveChannel(ch *streambot.Channel) (err error) {
 }
 
 func(db *DatabaseMock) GetChannelWithUid(uid string) (err error, ch *streambot.Channel) {
-	ch = &streambot.Channel{uid, "abc"}
+	if uid == db.MissingChannelId {
+		err = streambot.ErrChannelNotFound
+		return
+	}
+	ch = &streambot.Channel{Id: uid, Name: "abc", Version: db.ChannelVersion}
+	return
+}
+
+func(db *DatabaseMock) UpdateChannel(uid string, version int64, patch streambot.ChannelPatch) (
+	err error, 
+	ch *streambot.Channel,
+) {
+	if uid == db.MissingChannelId {
+		err = streambot.ErrChannelNotFound
+		return
+	}
+	ch = &streambot.Channel{Id: uid, Name: "abc", Description: "foo", Version: db.ChannelVersion}
+	if !ch.HasVersion(version) {
+		err, ch = streambot.ErrVersionMismatch, nil
+		return
+	}
+	patch.Apply(ch, time.Now().Unix())
+	db.UpdatedChannel = *ch
+	return
+}
+
+func(db *DatabaseMock) ListChannels(
+	filter streambot.ChannelFilter, 
+	order streambot.ChannelSort, 
+	page streambot.Page,
+) (err error, chs []streambot.Channel) {
+	db.ListFilter = filter
+	db.ListSort = order
+	chs = db.ListedChannels
+	if page.Offset < len(chs) {
+		chs = chs[page.Offset:]
+	} else {
+		chs = []streambot.Channel{}
+	}
+	if page.Limit > 0 && page.Limit < len(chs) {
+		chs = chs[:page.Limit]
+	}
 	return
 }
 
@@ -60,11 +116,47 @@ func(db *DatabaseMock) SaveChannelSubscription(
 	return
 }
 
-func(db *DatabaseMock) GetSubscriptionsForChannelWithUid(uid string) (
+func(db *DatabaseMock) DeleteChannelSubscription(fromChannelId string, toChannelId string) (err error) {
+	db.DeletedSubscription = TestChannelSubscriptionData{fromChannelId, toChannelId, 0}
+	return
+}
+
+func(db *DatabaseMock) GetSubscriptionsForChannelWithUid(uid string, depth int, page streambot.Page) (
+	err error, 
+	subs []streambot.Subscription,
+) {
+	db.SubscriptionsDepth = depth
+	db.SubscriptionsPage = page
+	subs = db.ChannelSubscriptions
+	if page.Offset < len(subs) {
+		subs = subs[page.Offset:]
+	} else {
+		subs = []streambot.Subscription{}
+	}
+	if page.Limit > 0 && page.Limit < len(subs) {
+		subs = subs[:page.Limit]
+	}
+	return
+}
+
+func(db *DatabaseMock) GetSubscribersForChannelWithUid(uid string) (
 	err error, 
 	chs []streambot.Channel,
 ) {
-	chs = db.ChannelSubscriptions
+	chs = db.ChannelSubscribers
+	return
+}
+
+func(db *DatabaseMock) DeleteChannel(uid string, version int64) (err error) {
+	if uid == db.MissingChannelId {
+		err = streambot.ErrChannelNotFound
+		return
+	}
+	if version != 0 && version != db.ChannelVersion {
+		err = streambot.ErrVersionMismatch
+		return
+	}
+	db.DeletedChannelId = uid
 	return
 }
 
@@ -76,7 +168,7 @@ func TestAPIPutChannelSavesChannelInDatabase(t *testing.T) {
 	// Instantiate database mock to be used by API server
 	db := new(DatabaseMock)
 	// Start API HTTP server with database mock
-	a := streambot.NewAPI(db)
+	a := streambot.NewAPI(db, NewTestStatter(t))
 	errChan := make(chan error)
 	a.Serve(8080, "/v1/", errChan)
 	go func() {
@@ -141,7 +233,7 @@ func TestAPIGetChannelRetrievedChannelFromDatabaseSuccess(t *testing.T) {
 	// Instantiate database mock to be used by API server
 	db := new(DatabaseMock)
 	// Start API HTTP server with database mock
-	a := streambot.NewAPI(db)
+	a := streambot.NewAPI(db, NewTestStatter(t))
 	errChan := make(chan error)
 	a.Serve(8081, "/v1/", errChan)
 	go func() {
@@ -193,7 +285,7 @@ func TestAPIPostChannelSubscriptionInDatabaseSuccess(t *testing.T) {
 	// Instantiate database mock to be used by API server
 	db := new(DatabaseMock)
 	// Start API HTTP server with database mock
-	a := streambot.NewAPI(db)
+	a := streambot.NewAPI(db, NewTestStatter(t))
 	errChan := make(chan error)
 	a.Serve(8082, "/v1/", errChan)
 	go func() {
@@ -236,14 +328,27 @@ func TestAPIPostChannelSubscriptionInDatabaseSuccess(t *testing.T) {
 	fmt.Println("Done")
 }
 
+type GetChannelSubscriptionResponse struct {
+	Channel 	GetChannelResponse 	`json:"channel"`
+	CreatedAt 	int64 				`json:"created_at"`
+	Hops 		int 				`json:"hops"`
+}
+
+type GetChannelSubscriptionsResponse struct {
+	Subscriptions 	[]GetChannelSubscriptionResponse 	`json:"subscriptions"`
+	NextCursor 		string 								`json:"next_cursor"`
+}
+
 func TestAPIGetChannelSubscriptionsFromDatabaseSucess(t *testing.T) {
 	// Define the subscribing channel's name
 	CHANNEL_UID := "foobarbazqux"
 	// Instantiate database mock to be used by API server
 	db := new(DatabaseMock)
-	db.ChannelSubscriptions = []streambot.Channel{streambot.Channel{Id: uuid.New(), Name: uuid.New()}}
+	db.ChannelSubscriptions = []streambot.Subscription{
+		streambot.Subscription{streambot.Channel{Id: uuid.New(), Name: uuid.New()}, time.Now().Unix(), 1},
+	}
 	// Start API HTTP server with database mock
-	a := streambot.NewAPI(db)
+	a := streambot.NewAPI(db, NewTestStatter(t))
 	errChan := make(chan error)
 	a.Serve(8083, "/v1/", errChan)
 	go func() {
@@ -262,30 +367,727 @@ func TestAPIGetChannelSubscriptionsFromDatabaseSucess(t *testing.T) {
 	defer res.Body.Close()
 	t.Logf("Request response body: %v", string(body))
 	// Unmarshal buffer to further handle the response
-	var response []streambot.Channel
-	err = json.Unmarshal(body, &response)
+	var envelope GetChannelSubscriptionsResponse
+	err = json.Unmarshal(body, &envelope)
 	if err != nil {
 		t.Fatalf("Unexpected error when unmarshalling JSON response `%s`: %v", string(body), err)
 	}
-	err, chs := db.GetSubscriptionsForChannelWithUid(CHANNEL_UID)
-	if len(chs) != len(db.ChannelSubscriptions) {
+	response := envelope.Subscriptions
+	if envelope.NextCursor != "" {
+		t.Fatalf("Unexpected next cursor `%s` for a single page of subscriptions", envelope.NextCursor)
+	}
+	if db.SubscriptionsDepth != 1 {
+		t.Fatalf("Expected API to fetch direct subscriptions only by default, given depth %d", 
+			db.SubscriptionsDepth)
+	}
+	subs := db.ChannelSubscriptions
+	if len(response) != len(subs) {
 		errMsgFormat := "Expected API to return exact same amount of subscribed channels as " +
 		"returned by database: %d, given %d"
-		t.Fatalf(errMsgFormat, len(chs), len(db.ChannelSubscriptions))
+		t.Fatalf(errMsgFormat, len(subs), len(response))
 	}
-	for i := range chs {
-		channel := response[i]
-		if chs[i].Name != channel.Name {
+	for i := range subs {
+		sub := response[i]
+		if subs[i].Channel.Name != sub.Channel.Name {
 			errMsgFormat := "Expected API response to contain channel's name `%s`, given `%s`"
-			t.Fatalf(errMsgFormat, chs[i].Name, channel.Name)
+			t.Fatalf(errMsgFormat, subs[i].Channel.Name, sub.Channel.Name)
 		}
-		if chs[i].Id != channel.Id {
+		if subs[i].Channel.Id != sub.Channel.Id {
 			errMsgFormat := "Expected API response to contain channel's ID `%s`, given `%s`"
-			t.Fatalf(errMsgFormat, chs[i].Id, channel.Id)
+			t.Fatalf(errMsgFormat, subs[i].Channel.Id, sub.Channel.Id)
+		}
+		if subs[i].CreatedAt != sub.CreatedAt {
+			errMsgFormat := "Expected API response to contain subscription time `%d`, given `%d`"
+			t.Fatalf(errMsgFormat, subs[i].CreatedAt, sub.CreatedAt)
+		}
+		if subs[i].Hops != sub.Hops {
+			errMsgFormat := "Expected API response to contain subscription hops `%d`, given `%d`"
+			t.Fatalf(errMsgFormat, subs[i].Hops, sub.Hops)
+		}
+	}
+	a.Shutdown()
+	<- a.Closed
+	fmt.Println("Done")
+
+}
+
+func TestAPIDeleteChannelRemovesChannelFromDatabase(t *testing.T) {
+	// Define the channel to delete and a channel unknown to the database
+	CHANNEL_UID := uuid.New()
+	MISSING_CHANNEL_UID := uuid.New()
+	// Instantiate database mock to be used by API server
+	db := new(DatabaseMock)
+	db.MissingChannelId = MISSING_CHANNEL_UID
+	// Start API HTTP server with database mock
+	a := streambot.NewAPI(db, NewTestStatter(t))
+	errChan := make(chan error)
+	a.Serve(8084, "/v1/", errChan)
+	go func() {
+		err := <- errChan
+		t.Fatalf("Unexpected error occurred when starting API: %v", err)
+	}()
+	cli := &http.Client{}
+	url := fmt.Sprintf("http://localhost:8084/v1/channels/%s", CHANNEL_UID)
+	req, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error when creating DELETE request: %v", err)
+	}
+	res, err := cli.Do(req)
+	if err != nil {
+		msgFormat := "Unexpected error on executing Channel DELETE request on URL `%s`: %v"
+		t.Fatalf(msgFormat, url, err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Fatalf("Unexpected status code on Channel DELETE response: %d, expected 200", res.StatusCode)
+	}
+	if db.DeletedChannelId != CHANNEL_UID {
+		format := "Deleted Channel Id `%s` does not match the expected `%s`."
+		t.Fatalf(format, db.DeletedChannelId, CHANNEL_UID)
+	}
+	// Verify deleting an unknown channel responds with not found
+	url = fmt.Sprintf("http://localhost:8084/v1/channels/%s", MISSING_CHANNEL_UID)
+	req, err = http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error when creating DELETE request: %v", err)
+	}
+	res, err = cli.Do(req)
+	if err != nil {
+		msgFormat := "Unexpected error on executing Channel DELETE request on URL `%s`: %v"
+		t.Fatalf(msgFormat, url, err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 404 {
+		t.Fatalf("Unexpected status code on unknown Channel DELETE response: %d, expected 404", 
+			res.StatusCode)
+	}
+	a.Shutdown()
+	<- a.Closed
+	fmt.Println("Done")
+}
+
+func TestAPIDeleteChannelSubscriptionInDatabaseSuccess(t *testing.T) {
+	// Define ID of channel unsubscribing
+	FROM_CHANNEL_ID := uuid.New()
+	// Define ID of channel to get unsubscribed
+	TO_CHANNEL_ID := uuid.New()
+	// Instantiate database mock to be used by API server
+	db := new(DatabaseMock)
+	// Start API HTTP server with database mock
+	a := streambot.NewAPI(db, NewTestStatter(t))
+	errChan := make(chan error)
+	a.Serve(8085, "/v1/", errChan)
+	go func() {
+		t.Fatalf("Unexpected error occurred when starting API: %v", <- errChan)
+	}()
+	urlFormat := "http://localhost:8085/v1/channels/%s/subscriptions/%s"
+	url := fmt.Sprintf(urlFormat, FROM_CHANNEL_ID, TO_CHANNEL_ID)
+	req, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error when creating DELETE request: %v", err)
+	}
+	res, err := (&http.Client{}).Do(req)
+	if err != nil {
+		msgFormat := "Unexpected error on Channel subscription DELETE request on URL `%s`: %v"
+		t.Fatalf(msgFormat, url, err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		msgFormat := "Unexpected status code on Channel subscription DELETE response: %d, expected 200"
+		t.Fatalf(msgFormat, res.StatusCode)
+	}
+	// Verify removed channel subscription matches the request
+	if db.DeletedSubscription.FromChannelId != FROM_CHANNEL_ID {
+		format := "Deleted Channel subscription source channel `%s` does not match the expected `%s`."
+		t.Fatalf(format, db.DeletedSubscription.FromChannelId, FROM_CHANNEL_ID)
+	}
+	if db.DeletedSubscription.ToChannelId != TO_CHANNEL_ID {
+		format := "Deleted Channel subscription target channel `%s` does not match the expected `%s`."
+		t.Fatalf(format, db.DeletedSubscription.ToChannelId, TO_CHANNEL_ID)
+	}
+	a.Shutdown()
+	<- a.Closed
+	fmt.Println("Done")
+}
+
+func TestAPIPostChannelSubscriptionDefaultsToServerTime(t *testing.T) {
+	// Instantiate database mock to be used by API server
+	db := new(DatabaseMock)
+	// Start API HTTP server with database mock
+	a := streambot.NewAPI(db, NewTestStatter(t))
+	errChan := make(chan error)
+	a.Serve(8086, "/v1/", errChan)
+	go func() {
+		t.Fatalf("Unexpected error occurred when starting API: %v", <- errChan)
+	}()
+	url := fmt.Sprintf("http://localhost:8086/v1/channels/%s/subscriptions", uuid.New())
+	// Post a subscription request lacking the creation time
+	beforeRequest := time.Now().Unix()
+	body := bytes.NewReader([]byte(fmt.Sprintf("{\"channel_id\":\"%s\"}", uuid.New())))
+	res, err := http.Post(url, "application/json", body)
+	if err != nil {
+		msgFormat := "Unexpected error on Channel subscription request on URL `%s`: %v"
+		t.Fatalf(msgFormat, url, err)
+	}
+	if res.StatusCode != 200 {
+		msgFormat := "Unexpected status code on Channel subscription response: %d, expected 200"
+		t.Fatalf(msgFormat, res.StatusCode)
+	}
+	// Verify the subscription was saved with the server time
+	if db.SavedSubscription.CreationTime < beforeRequest {
+		format := "Saved Channel subscription time `%d` is expected to default to server time `%d`"
+		t.Fatalf(format, db.SavedSubscription.CreationTime, beforeRequest)
+	}
+	a.Shutdown()
+	<- a.Closed
+	fmt.Println("Done")
+}
+
+func TestAPIGetChannelSubscribersFromDatabaseSuccess(t *testing.T) {
+	// Define the subscribed channel's name
+	CHANNEL_UID := "foobarbazqux"
+	// Instantiate database mock to be used by API server
+	db := new(DatabaseMock)
+	db.ChannelSubscribers = []streambot.Channel{
+		streambot.Channel{Id: uuid.New(), Name: uuid.New()},
+		streambot.Channel{Id: uuid.New(), Name: uuid.New()},
+	}
+	// Start API HTTP server with database mock
+	a := streambot.NewAPI(db, NewTestStatter(t))
+	errChan := make(chan error)
+	a.Serve(8087, "/v1/", errChan)
+	go func() {
+		t.Fatalf("Unexpected error occurred when starting API: %v", <- errChan)
+	}()
+	url := fmt.Sprintf("http://localhost:8087/v1/channels/%s/subscribers", CHANNEL_UID)
+	res, err := http.Get(url)
+	if err != nil {
+		msgFormat := "Unexpected error on executing Channel subscribers fetch GET request" +
+		" on URL `%s`: %v"
+		t.Fatalf(msgFormat, url, err)
+	}
+	// Read the request response into a raw buffer
+	body, err := ioutil.ReadAll(res.Body)
+	defer res.Body.Close()
+	t.Logf("Request response body: %v", string(body))
+	// Unmarshal buffer to further handle the response
+	var response []GetChannelResponse
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		t.Fatalf("Unexpected error when unmarshalling JSON response `%s`: %v", string(body), err)
+	}
+	if len(response) != len(db.ChannelSubscribers) {
+		errMsgFormat := "Expected API to return exact same amount of subscribing channels as " +
+		"returned by database: %d, given %d"
+		t.Fatalf(errMsgFormat, len(db.ChannelSubscribers), len(response))
+	}
+	for i, ch := range db.ChannelSubscribers {
+		if response[i].Id != ch.Id || response[i].Name != ch.Name {
+			errMsgFormat := "Expected API response to contain subscriber `%v`, given `%v`"
+			t.Fatalf(errMsgFormat, ch, response[i])
+		}
+	}
+	a.Shutdown()
+	<- a.Closed
+	fmt.Println("Done")
+}
+
+func TestAPIGetChannelSubscriptionsDepthIsBounded(t *testing.T) {
+	CHANNEL_UID := uuid.New()
+	// Instantiate database mock to be used by API server
+	db := new(DatabaseMock)
+	db.ChannelSubscriptions = []streambot.Subscription{}
+	// Start API HTTP server with database mock and a low maximum depth
+	a := streambot.NewAPI(db, NewTestStatter(t))
+	a.Channels.MaxSubscriptionDepth = 3
+	errChan := make(chan error)
+	a.Serve(8088, "/v1/", errChan)
+	go func() {
+		t.Fatalf("Unexpected error occurred when starting API: %v", <- errChan)
+	}()
+	urlFormat := "http://localhost:8088/v1/channels/%s/subscriptions?depth=%s"
+	expectations := []struct {
+		Depth 		string
+		Status 		int
+		DBDepth 	int
+	}{
+		{"2", 200, 2},
+		{"10", 200, 3},
+		{"0", 400, 0},
+		{"abc", 400, 0},
+	}
+	for _, expected := range expectations {
+		db.SubscriptionsDepth = 0
+		url := fmt.Sprintf(urlFormat, CHANNEL_UID, expected.Depth)
+		res, err := http.Get(url)
+		if err != nil {
+			t.Fatalf("Unexpected error on executing GET request on URL `%s`: %v", url, err)
+		}
+		res.Body.Close()
+		if res.StatusCode != expected.Status {
+			msgFormat := "Expected status code %d for depth `%s`, given %d"
+			t.Fatalf(msgFormat, expected.Status, expected.Depth, res.StatusCode)
+		}
+		if db.SubscriptionsDepth != expected.DBDepth {
+			msgFormat := "Expected database to be queried with depth %d for depth `%s`, given %d"
+			t.Fatalf(msgFormat, expected.DBDepth, expected.Depth, db.SubscriptionsDepth)
+		}
+	}
+	a.Shutdown()
+	<- a.Closed
+	fmt.Println("Done")
+}
+
+func TestAPIGetChannelSubscriptionsPagination(t *testing.T) {
+	CHANNEL_UID := uuid.New()
+	// Instantiate database mock with five subscriptions to be used by API server
+	db := new(DatabaseMock)
+	for i := 0; i < 5; i++ {
+		ch := streambot.Channel{Id: uuid.New(), Name: uuid.New()}
+		db.ChannelSubscriptions = append(db.ChannelSubscriptions, streambot.Subscription{ch, 0, 1})
+	}
+	// Start API HTTP server with database mock
+	a := streambot.NewAPI(db, NewTestStatter(t))
+	errChan := make(chan error)
+	a.Serve(8089, "/v1/", errChan)
+	go func() {
+		t.Fatalf("Unexpected error occurred when starting API: %v", <- errChan)
+	}()
+	// Page through the subscriptions two at a time and collect the channel IDs
+	var ids []string
+	url := fmt.Sprintf("http://localhost:8089/v1/channels/%s/subscriptions?limit=2", CHANNEL_UID)
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatalf("Expected subscriptions to be paged through within 3 pages")
+		}
+		res, err := http.Get(url)
+		if err != nil {
+			t.Fatalf("Unexpected error on executing GET request on URL `%s`: %v", url, err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		var envelope GetChannelSubscriptionsResponse
+		err = json.Unmarshal(body, &envelope)
+		if err != nil {
+			t.Fatalf("Unexpected error when unmarshalling JSON response `%s`: %v", string(body), err)
+		}
+		if len(envelope.Subscriptions) > 2 {
+			t.Fatalf("Expected at most 2 subscriptions per page, given %d", len(envelope.Subscriptions))
+		}
+		for _, sub := range envelope.Subscriptions {
+			ids = append(ids, sub.Channel.Id)
+		}
+		if envelope.NextCursor == "" {
+			break
+		}
+		urlFormat := "http://localhost:8089/v1/channels/%s/subscriptions?limit=2&cursor=%s"
+		url = fmt.Sprintf(urlFormat, CHANNEL_UID, envelope.NextCursor)
+	}
+	if len(ids) != len(db.ChannelSubscriptions) {
+		t.Fatalf("Expected %d subscriptions in total, given %d", len(db.ChannelSubscriptions), len(ids))
+	}
+	for i, sub := range db.ChannelSubscriptions {
+		if ids[i] != sub.Channel.Id {
+			t.Fatalf("Expected subscription %d to be `%s`, given `%s`", i, sub.Channel.Id, ids[i])
+		}
+	}
+	// Verify a malformed cursor is rejected
+	url = fmt.Sprintf("http://localhost:8089/v1/channels/%s/subscriptions?cursor=foo", CHANNEL_UID)
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("Unexpected error on executing GET request on URL `%s`: %v", url, err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 400 {
+		t.Fatalf("Expected status code 400 for malformed cursor, given %d", res.StatusCode)
+	}
+	a.Shutdown()
+	<- a.Closed
+	fmt.Println("Done")
+}
+
+type ErrorResponse struct {
+	Error struct {
+		Code 		string `json:"code"`
+		Message 	string `json:"message"`
+		RequestId 	string `json:"request_id"`
+		Details 	[]struct {
+			Field 	string `json:"field"`
+			Message string `json:"message"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+func ReadErrorResponse(t *testing.T, res *http.Response) (out ErrorResponse) {
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatalf("Unexpected error when reading error response body: %v", err)
+	}
+	err = json.Unmarshal(body, &out)
+	if err != nil {
+		t.Fatalf("Unexpected error when unmarshalling JSON error response `%s`: %v", string(body), err)
+	}
+	return
+}
+
+func TestAPIRespondsErrorsAsJSON(t *testing.T) {
+	MISSING_CHANNEL_UID := uuid.New()
+	REQUEST_ID := uuid.New()
+	// Instantiate database mock to be used by API server
+	db := new(DatabaseMock)
+	db.MissingChannelId = MISSING_CHANNEL_UID
+	// Start API HTTP server with database mock
+	a := streambot.NewAPI(db, NewTestStatter(t))
+	errChan := make(chan error)
+	a.Serve(8090, "/v1/", errChan)
+	go func() {
+		t.Fatalf("Unexpected error occurred when starting API: %v", <- errChan)
+	}()
+	// Verify a not found error echoes the request id given by the client
+	url := fmt.Sprintf("http://localhost:8090/v1/channels/%s", MISSING_CHANNEL_UID)
+	req, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error when creating DELETE request: %v", err)
+	}
+	req.Header.Set("X-Request-Id", REQUEST_ID)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Unexpected error on executing DELETE request on URL `%s`: %v", url, err)
+	}
+	out := ReadErrorResponse(t, res)
+	if res.StatusCode != 404 || out.Error.Code != "not_found" || out.Error.Message == "" {
+		t.Fatalf("Expected not found error with status 404, given %d and %v", res.StatusCode, out)
+	}
+	if out.Error.RequestId != REQUEST_ID || res.Header.Get("X-Request-Id") != REQUEST_ID {
+		t.Fatalf("Expected request id `%s` in error response, given %v", REQUEST_ID, out)
+	}
+	// Verify an invalid argument error carries a generated request id
+	url = fmt.Sprintf("http://localhost:8090/v1/channels/%s/subscriptions?depth=abc", uuid.New())
+	res, err = http.Get(url)
+	if err != nil {
+		t.Fatalf("Unexpected error on executing GET request on URL `%s`: %v", url, err)
+	}
+	out = ReadErrorResponse(t, res)
+	if res.StatusCode != 400 || out.Error.Code != "invalid_argument" {
+		t.Fatalf("Expected invalid argument error with status 400, given %d and %v", res.StatusCode, out)
+	}
+	if match, _ := regexp.MatchString(UUID_FORMAT, out.Error.RequestId); !match {
+		t.Fatalf("Expected generated request id in error response, given `%s`", out.Error.RequestId)
+	}
+	a.Shutdown()
+	<- a.Closed
+	fmt.Println("Done")
+}
+
+func TestAPIValidatesRequestBodies(t *testing.T) {
+	FROM_CHANNEL_ID := uuid.New()
+	MISSING_CHANNEL_UID := uuid.New()
+	// Instantiate database mock to be used by API server
+	db := new(DatabaseMock)
+	db.MissingChannelId = MISSING_CHANNEL_UID
+	// Start API HTTP server with database mock
+	a := streambot.NewAPI(db, NewTestStatter(t))
+	errChan := make(chan error)
+	a.Serve(8091, "/v1/", errChan)
+	go func() {
+		t.Fatalf("Unexpected error occurred when starting API: %v", <- errChan)
+	}()
+	cli := &http.Client{}
+	subscriptionsURL := fmt.Sprintf("http://localhost:8091/v1/channels/%s/subscriptions", FROM_CHANNEL_ID)
+	for _, expected := range []struct{
+		Method 	string
+		URL 	string
+		Body 	string
+		Field 	string
+	}{
+		{"PUT", "http://localhost:8091/v1/channels", "{\"name\":\" \"}", "name"},
+		{"PUT", "http://localhost:8091/v1/channels", fmt.Sprintf("{\"name\":\"%0101d\"}", 0), "name"},
+		{"POST", subscriptionsURL, "{}", "channel_id"},
+		{"POST", subscriptionsURL, "{\"channel_id\":\"foo bar\"}", "channel_id"},
+		{"POST", subscriptionsURL, fmt.Sprintf("{\"channel_id\":\"%s\"}", FROM_CHANNEL_ID), "channel_id"},
+		{"POST", subscriptionsURL, fmt.Sprintf("{\"channel_id\":\"%s\"}", MISSING_CHANNEL_UID), "channel_id"},
+		{"POST", subscriptionsURL, fmt.Sprintf("{\"channel_id\":\"%s\",\"created_at\":-1}", uuid.New()), 
+			"created_at"},
+	} {
+		req, err := http.NewRequest(expected.Method, expected.URL, bytes.NewReader([]byte(expected.Body)))
+		if err != nil {
+			t.Fatalf("Unexpected error when creating %s request: %v", expected.Method, err)
+		}
+		res, err := cli.Do(req)
+		if err != nil {
+			t.Fatalf("Unexpected error on executing %s request on URL `%s`: %v", expected.Method, expected.URL, err)
+		}
+		out := ReadErrorResponse(t, res)
+		if res.StatusCode != 422 || out.Error.Code != "validation_failed" {
+			msgFormat := "Expected validation error with status 422 for body `%s`, given %d and %v"
+			t.Fatalf(msgFormat, expected.Body, res.StatusCode, out)
+		}
+		if len(out.Error.Details) != 1 || out.Error.Details[0].Field != expected.Field {
+			msgFormat := "Expected validation error of field `%s` for body `%s`, given %v"
+			t.Fatalf(msgFormat, expected.Field, expected.Body, out.Error.Details)
+		}
+	}
+	// Verify invalid requests never reached the database
+	if db.SavedChannel.Id != "" || db.SavedSubscription.FromChannelId != "" {
+		t.Fatalf("Expected invalid requests not to be saved, given %v", db)
+	}
+	a.Shutdown()
+	<- a.Closed
+	fmt.Println("Done")
+}
+
+func TestAPIPatchChannelAppliesMergePatch(t *testing.T) {
+	CHANNEL_UID := uuid.New()
+	MISSING_CHANNEL_UID := uuid.New()
+	// Instantiate database mock to be used by API server
+	db := new(DatabaseMock)
+	db.MissingChannelId = MISSING_CHANNEL_UID
+	// Start API HTTP server with database mock
+	a := streambot.NewAPI(db, NewTestStatter(t))
+	errChan := make(chan error)
+	a.Serve(8092, "/v1/", errChan)
+	go func() {
+		t.Fatalf("Unexpected error occurred when starting API: %v", <- errChan)
+	}()
+	cli := &http.Client{}
+	patch := func(uid string, body string) (res *http.Response) {
+		url := fmt.Sprintf("http://localhost:8092/v1/channels/%s", uid)
+		req, err := http.NewRequest("PATCH", url, bytes.NewReader([]byte(body)))
+		if err != nil {
+			t.Fatalf("Unexpected error when creating PATCH request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		res, err = cli.Do(req)
+		if err != nil {
+			t.Fatalf("Unexpected error on executing PATCH request on URL `%s`: %v", url, err)
+		}
+		return
+	}
+	// Verify renaming keeps omitted fields and null clears optional fields
+	res := patch(CHANNEL_UID, "{\"name\":\"bar\",\"owner_id\":null}")
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Fatalf("Unexpected status code on Channel PATCH response: %d, expected 200", res.StatusCode)
+	}
+	var out struct {
+		Id 			string `json:"id"`
+		Name 		string `json:"name"`
+		Description string `json:"description"`
+		UpdatedAt 	int64 `json:"updated_at"`
+	}
+	if err = json.Unmarshal(body, &out); err != nil {
+		t.Fatalf("Unexpected error when unmarshalling JSON response `%s`: %v", string(body), err)
+	}
+	if out.Id != CHANNEL_UID || out.Name != "bar" || out.Description != "foo" || out.UpdatedAt == 0 {
+		t.Fatalf("Expected renamed Channel `%s` in PATCH response, given %v", CHANNEL_UID, out)
+	}
+	if db.UpdatedChannel.Name != "bar" || db.UpdatedChannel.OwnerId != "" {
+		t.Fatalf("Expected Channel to be updated in database, given %v", db.UpdatedChannel)
+	}
+	// Verify immutable and required fields are rejected
+	res = patch(CHANNEL_UID, "{\"id\":\"foo\",\"name\":null}")
+	out422 := ReadErrorResponse(t, res)
+	if res.StatusCode != 422 || len(out422.Error.Details) != 2 {
+		t.Fatalf("Expected validation error for `id` and `name`, given %d and %v", res.StatusCode, out422)
+	}
+	// Verify unknown Channels are not found
+	res = patch(MISSING_CHANNEL_UID, "{\"name\":\"bar\"}")
+	res.Body.Close()
+	if res.StatusCode != 404 {
+		t.Fatalf("Unexpected status code on unknown Channel PATCH response: %d, expected 404", res.StatusCode)
+	}
+	a.Shutdown()
+	<- a.Closed
+	fmt.Println("Done")
+}
+
+func TestAPIChannelRequestsHonourEntityTags(t *testing.T) {
+	CHANNEL_UID := uuid.New()
+	// Instantiate database mock with a Channel at version 3 to be used by API server
+	db := new(DatabaseMock)
+	db.ChannelVersion = 3
+	// Start API HTTP server with database mock
+	a := streambot.NewAPI(db, NewTestStatter(t))
+	errChan := make(chan error)
+	a.Serve(8093, "/v1/", errChan)
+	go func() {
+		t.Fatalf("Unexpected error occurred when starting API: %v", <- errChan)
+	}()
+	cli := &http.Client{}
+	url := fmt.Sprintf("http://localhost:8093/v1/channels/%s", CHANNEL_UID)
+	do := func(method string, header string, etag string, body string) (res *http.Response) {
+		req, err := http.NewRequest(method, url, bytes.NewReader([]byte(body)))
+		if err != nil {
+			t.Fatalf("Unexpected error when creating %s request: %v", method, err)
+		}
+		if header != "" {
+			req.Header.Set(header, etag)
+		}
+		res, err = cli.Do(req)
+		if err != nil {
+			t.Fatalf("Unexpected error on executing %s request on URL `%s`: %v", method, url, err)
+		}
+		res.Body.Close()
+		return
+	}
+	for _, expected := range []struct{
+		Method 	string
+		Header 	string
+		ETag 	string
+		Status 	int
+	}{
+		{"GET", "", "", 200},
+		{"GET", "If-None-Match", "\"3\"", 304},
+		{"GET", "If-None-Match", "W/\"2\", W/\"3\"", 304},
+		{"GET", "If-None-Match", "\"2\"", 200},
+		{"PATCH", "If-Match", "\"2\"", 412},
+		{"PATCH", "If-Match", "W/\"3\"", 412},
+		{"PATCH", "If-Match", "\"3\"", 200},
+		{"DELETE", "If-Match", "\"2\"", 412},
+		{"DELETE", "If-Match", "\"3\"", 200},
+	} {
+		res := do(expected.Method, expected.Header, expected.ETag, "{\"name\":\"foo\"}")
+		if res.StatusCode != expected.Status {
+			msgFormat := "Expected status %d on %s with %s `%s`, given %d"
+			t.Fatalf(msgFormat, expected.Status, expected.Method, expected.Header, expected.ETag, res.StatusCode)
+		}
+		if expected.Method == "GET" && res.Header.Get("ETag") != "\"3\"" {
+			t.Fatalf("Expected ETag `\"3\"` on Channel GET response, given `%s`", res.Header.Get("ETag"))
 		}
 	}
 	a.Shutdown()
 	<- a.Closed
 	fmt.Println("Done")
+}
 
+func TestAPIHidesDetailsOfUnavailableBackend(t *testing.T) {
+	// Take the mock Rexster server down right away, so that the database cannot be reached
+	rexster := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	host := strings.Split(rexster.URL, "http://")[1]
+	rexster.Close()
+	db, err := streambot.NewGraphDatabase("foobarbaz", []string{host})
+	if err != nil {
+		t.Fatalf("Unexpected error when creating graph database client: %v", err)
+	}
+	a := streambot.NewAPI(db, NewTestStatter(t))
+	errChan := make(chan error)
+	a.Serve(8100, "/v1/", errChan)
+	go func() {
+		t.Fatalf("Unexpected error occurred when starting API: %v", <- errChan)
+	}()
+	url := fmt.Sprintf("http://localhost:8100/v1/channels/%s", uuid.New())
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("Unexpected error on executing GET request on URL `%s`: %v", url, err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != 503 || strings.Contains(string(body), host) {
+		t.Fatalf("Expected 503 without backend details, given status %d with `%s`", res.StatusCode, body)
+	}
+	a.Shutdown()
+	<- a.Closed
+	fmt.Println("Done")
+}
+
+func TestAPIChannelStoredBeforeVersioningHonoursEntityTags(t *testing.T) {
+	CHANNEL_UID := uuid.New()
+	// Store a Channel the way it was persisted before Channels were versioned
+	dir, db := TempBoltDatabase(t)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+	err := db.DB.Update(func(tx *bolt.Tx) error {
+		legacy := fmt.Sprintf("{\"Id\":\"%s\",\"Name\":\"foo\"}", CHANNEL_UID)
+		return tx.Bucket([]byte("channels")).Put([]byte(CHANNEL_UID), []byte(legacy))
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error when storing legacy Channel: %v", err)
+	}
+	a := streambot.NewAPI(db, NewTestStatter(t))
+	errChan := make(chan error)
+	a.Serve(8099, "/v1/", errChan)
+	go func() {
+		t.Fatalf("Unexpected error occurred when starting API: %v", <- errChan)
+	}()
+	url := fmt.Sprintf("http://localhost:8099/v1/channels/%s", CHANNEL_UID)
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("Unexpected error on executing GET request on URL `%s`: %v", url, err)
+	}
+	res.Body.Close()
+	etag := res.Header.Get("ETag")
+	if res.StatusCode != 200 || etag != "\"1\"" {
+		t.Fatalf("Expected legacy Channel at version 1, given status %d with ETag `%s`", res.StatusCode, etag)
+	}
+	// The entity tag of the GET response allows to change the Channel
+	req, err := http.NewRequest("PATCH", url, bytes.NewReader([]byte("{\"name\":\"bar\"}")))
+	if err != nil {
+		t.Fatalf("Unexpected error when creating PATCH request: %v", err)
+	}
+	req.Header.Set("If-Match", etag)
+	res, err = (&http.Client{}).Do(req)
+	if err != nil {
+		t.Fatalf("Unexpected error on executing PATCH request on URL `%s`: %v", url, err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 200 || res.Header.Get("ETag") != "\"2\"" {
+		msgFormat := "Expected legacy Channel to be patched to version 2, given status %d with ETag `%s`"
+		t.Fatalf(msgFormat, res.StatusCode, res.Header.Get("ETag"))
+	}
+	a.Shutdown()
+	<- a.Closed
+	fmt.Println("Done")
+}
+
+type ListChannelsResponse struct {
+	Channels 	[]GetChannelResponse 	`json:"channels"`
+	NextCursor 	string 					`json:"next_cursor"`
+}
+
+func TestAPIListChannels(t *testing.T) {
+	// Instantiate database mock with three Channels to be used by API server
+	db := new(DatabaseMock)
+	for i := 0; i < 3; i++ {
+		db.ListedChannels = append(db.ListedChannels, *streambot.NewChannel(fmt.Sprintf("foo-%d", i)))
+	}
+	// Start API HTTP server with database mock
+	a := streambot.NewAPI(db, NewTestStatter(t))
+	errChan := make(chan error)
+	a.Serve(8094, "/v1/", errChan)
+	go func() {
+		t.Fatalf("Unexpected error occurred when starting API: %v", <- errChan)
+	}()
+	url := "http://localhost:8094/v1/channels?name_prefix=foo&sort=-created_at&limit=2"
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("Unexpected error on executing GET request on URL `%s`: %v", url, err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	var out ListChannelsResponse
+	if err = json.Unmarshal(body, &out); err != nil {
+		t.Fatalf("Unexpected error when unmarshalling JSON response `%s`: %v", string(body), err)
+	}
+	if len(out.Channels) != 2 || out.Channels[0].Id != db.ListedChannels[0].Id || out.NextCursor == "" {
+		t.Fatalf("Expected first page of 2 Channels with a next cursor, given %v", out)
+	}
+	if db.ListFilter.NamePrefix != "foo" || db.ListSort.Field != streambot.SortChannelsByCreatedAt || 
+		!db.ListSort.Descending {
+		t.Fatalf("Expected filter and sort order to be passed to database, given %v and %v", 
+			db.ListFilter, db.ListSort)
+	}
+	// Verify unknown sort fields are rejected
+	res, err = http.Get("http://localhost:8094/v1/channels?sort=owner_id")
+	if err != nil {
+		t.Fatalf("Unexpected error on executing GET request: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 400 {
+		t.Fatalf("Expected status code 400 for unknown sort field, given %d", res.StatusCode)
+	}
+	a.Shutdown()
+	<- a.Closed
+	fmt.Println("Done")
 }
\ No newline at end of file
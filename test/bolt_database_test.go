@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"../src/streambot"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+func TempBoltDatabase(t *testing.T) (dir string, db *streambot.BoltDatabase) {
+	dir, err := ioutil.TempDir("", "streambot")
+	if err != nil {
+		t.Fatalf("Unexpected error when creating temporary data directory: %v", err)
+	}
+	db, err = streambot.NewBoltDatabase(filepath.Join(dir, "data"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Unexpected error when opening Bolt database: %v", err)
+	}
+	return
+}
+
+func TestBoltDatabaseGraphSemantics(t *testing.T) {
+	dir, db := TempBoltDatabase(t)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+	VerifyDatabaseGraphSemantics(t, db)
+}
+
+func TestBoltDatabasePersistsAcrossRestarts(t *testing.T) {
+	dir, db := TempBoltDatabase(t)
+	defer os.RemoveAll(dir)
+	from := streambot.NewChannel("foo")
+	to := streambot.NewChannel("bar")
+	for _, ch := range []*streambot.Channel{from, to} {
+		if err := db.SaveChannel(ch); err != nil {
+			t.Fatalf("Unexpected error when saving Channel: %v", err)
+		}
+	}
+	if err := db.SaveChannelSubscription(from.Id, to.Id, 42); err != nil {
+		t.Fatalf("Unexpected error when saving Channel subscription: %v", err)
+	}
+	db.Close()
+	// Reopen the database from the same data directory
+	db, err := streambot.NewBoltDatabase(filepath.Join(dir, "data"))
+	if err != nil {
+		t.Fatalf("Unexpected error when reopening Bolt database: %v", err)
+	}
+	defer db.Close()
+	err, ch := db.GetChannelWithUid(from.Id)
+	if err != nil || ch.Name != from.Name {
+		t.Fatalf("Expected Channel `%s` to be persisted, given %v with error %v", from.Name, ch, err)
+	}
+	err, subs := db.GetSubscriptionsForChannelWithUid(from.Id, 1, streambot.Page{})
+	if err != nil || len(subs) != 1 || subs[0].Channel.Id != to.Id || subs[0].CreatedAt != 42 {
+		t.Fatalf("Expected subscription to `%s` to be persisted, given %v with error %v", to.Id, subs, err)
+	}
+}
\ No newline at end of file
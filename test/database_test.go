@@ -5,7 +5,6 @@ import (
 	"../src/streambot"
 	"net/http"
 	"net/http/httptest"
-	"net/url"
 	"strings"
 	"io/ioutil"
 	"encoding/json"
@@ -14,6 +13,8 @@ import (
 	"math/rand"
 	"code.google.com/p/go-uuid/uuid"
 	"time"
+	"errors"
+	"reflect"
 )
 
 type NewChannelRequestBody struct {
@@ -55,6 +56,30 @@ func RequestBody(t *testing.T, r *http.Request) (body NewChannelRequestBody) {
 	return
 }
 
+type GremlinRequestBody struct {
+	Script 	string 					`json:"script"`
+	Params 	map[string]interface{} 	`json:"params"`
+}
+
+func GremlinRequest(t *testing.T, graph string, r *http.Request) (body GremlinRequestBody) {
+	// Verify scripts are posted to the Gremlin extension of the graph
+	expectedURL := fmt.Sprintf("/graphs/%s/tp/gremlin", graph)
+	if r.Method != "POST" || r.URL.String() != expectedURL {
+		msgFormat := "Expected Gremlin script to be posted to `%s`, given %s on `%s`"
+		t.Fatalf(msgFormat, expectedURL, r.Method, r.URL.String())
+	}
+	rawData, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	err = json.Unmarshal(rawData, &body)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	return
+}
+
 func TestSaveNewChannelInGraph(t *testing.T) {
 
 	GRAPH 		:= "foobarbaz"
@@ -180,51 +205,185 @@ func TestGetChannelInGraph(t *testing.T) {
 		msgFormat := "Expected retrieved Channel to have name `%s`, given `%s`"
 		t.Fatalf(msgFormat, CHANNEL, retrievedChannel.Name)
 	}
+	// Verify a vertex stored before Channel metadata was introduced is read with empty metadata
+	if retrievedChannel.Description != "" || retrievedChannel.CreatedAt != 0 {
+		t.Fatalf("Expected retrieved Channel to lack metadata, given %v", retrievedChannel)
+	}
+	// Verify a vertex stored before Channels were versioned is read at the initial version
+	if retrievedChannel.Version != streambot.InitialChannelVersion {
+		t.Fatalf("Expected retrieved Channel at version 1, given %d", retrievedChannel.Version)
+	}
 	// Verify server was called as expected
 	if !serverCalled {
 		t.Fatalf("Expected to have posted Channel vertex data to graph database server")
 	}
 }
 
+func TestUpdateChannelInGraph(t *testing.T) {
+	GRAPH 		:= "foobarbaz"
+	CHANNEL_UID := uuid.New()
+	NAME 		:= "bar"
+
+	// Keep track on the update script being sent during test
+	scriptCalled := false
+
+	// Set up a mock server that knows the Channel vertex and accepts the update script
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != fmt.Sprintf("/graphs/%s/tp/gremlin", GRAPH) {
+			resFormat := "{\"version\":\"2.4.0\",\"results\":[{\"uid\":\"%s\",\"name\":\"foo\"," +
+			"\"description\":\"baz\",\"_id\":1,\"_type\":\"vertex\"}],\"totalSize\":1,\"queryTime\":1.0}"
+			fmt.Fprintln(w, fmt.Sprintf(resFormat, CHANNEL_UID))
+			return
+		}
+		body := GremlinRequest(t, GRAPH, r)
+		if body.Params["uid"] != CHANNEL_UID || body.Params["name"] != NAME || 
+			body.Params["description"] != "baz" || body.Params["updated_at"] == float64(0) {
+			t.Fatalf("Unexpected update script parameters %v", body.Params)
+		}
+		fmt.Fprintln(w, "{\"results\":[],\"success\":true,\"version\":\"2.4.0\",\"queryTime\":1.0}")
+		scriptCalled = true
+	}
+	err, r, db := MockRexsterServerAndInstantiateGraphDatabase(t, GRAPH, handler)
+	defer r.Close()
+	if err != nil {
+		t.Fatalf("Unexpected error in MockRexsterServerAndInstantiateGraphDatabase: %v", err)
+	}
+	err, ch := db.UpdateChannel(CHANNEL_UID, 0, streambot.ChannelPatch{Name: &NAME})
+	if err != nil {
+		t.Fatalf("Unexpected error when updating Channel: %v", err)
+	}
+	if ch.Name != NAME || ch.Description != "baz" {
+		t.Fatalf("Expected Channel renamed to `%s` keeping its description, given %v", NAME, ch)
+	}
+	if !scriptCalled {
+		t.Fatalf("Expected to have sent Channel update script to graph database server")
+	}
+}
+
+func TestUpdateOutdatedChannelInGraph(t *testing.T) {
+	GRAPH 		:= "foobarbaz"
+	CHANNEL_UID := uuid.New()
+	NAME 		:= "bar"
+
+	// Set up a mock server that knows the Channel vertex at version 2, but refuses the update 
+	// script as if the vertex was changed concurrently
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != fmt.Sprintf("/graphs/%s/tp/gremlin", GRAPH) {
+			resFormat := "{\"version\":\"2.4.0\",\"results\":[{\"uid\":\"%s\",\"name\":\"foo\"," +
+			"\"version\":2,\"_id\":1,\"_type\":\"vertex\"}],\"totalSize\":1,\"queryTime\":1.0}"
+			fmt.Fprintln(w, fmt.Sprintf(resFormat, CHANNEL_UID))
+			return
+		}
+		body := GremlinRequest(t, GRAPH, r)
+		if body.Params["current"] != float64(2) {
+			t.Fatalf("Expected update script to be conditional on version 2, given %v", body.Params)
+		}
+		fmt.Fprintln(w, "{\"results\":[false],\"success\":true,\"version\":\"2.4.0\",\"queryTime\":1.0}")
+	}
+	err, r, db := MockRexsterServerAndInstantiateGraphDatabase(t, GRAPH, handler)
+	defer r.Close()
+	if err != nil {
+		t.Fatalf("Unexpected error in MockRexsterServerAndInstantiateGraphDatabase: %v", err)
+	}
+	err, _ = db.UpdateChannel(CHANNEL_UID, 1, streambot.ChannelPatch{Name: &NAME})
+	if !errors.Is(err, streambot.ErrVersionMismatch) {
+		t.Fatalf("Expected ErrVersionMismatch when updating outdated Channel, given %v", err)
+	}
+	err, _ = db.UpdateChannel(CHANNEL_UID, 2, streambot.ChannelPatch{Name: &NAME})
+	if !errors.Is(err, streambot.ErrVersionMismatch) {
+		t.Fatalf("Expected ErrVersionMismatch when Channel changed concurrently, given %v", err)
+	}
+}
+
+func TestUpdateChannelWithoutVersionInGraph(t *testing.T) {
+	GRAPH 		:= "foobarbaz"
+	CHANNEL_UID := uuid.New()
+	NAME 		:= "bar"
+
+	// Set up a mock server that refuses the first update script as if the vertex was changed 
+	// concurrently and takes the second one
+	updates := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != fmt.Sprintf("/graphs/%s/tp/gremlin", GRAPH) {
+			resFormat := "{\"version\":\"2.4.0\",\"results\":[{\"uid\":\"%s\",\"name\":\"foo\"," +
+			"\"version\":%d,\"_id\":1,\"_type\":\"vertex\"}],\"totalSize\":1,\"queryTime\":1.0}"
+			fmt.Fprintln(w, fmt.Sprintf(resFormat, CHANNEL_UID, 2 + updates))
+			return
+		}
+		updates++
+		fmt.Fprintf(w, "{\"results\":[%v],\"success\":true,\"version\":\"2.4.0\",\"queryTime\":1.0}\n", 
+			updates > 1)
+	}
+	err, r, db := MockRexsterServerAndInstantiateGraphDatabase(t, GRAPH, handler)
+	defer r.Close()
+	if err != nil {
+		t.Fatalf("Unexpected error in MockRexsterServerAndInstantiateGraphDatabase: %v", err)
+	}
+	// Without a version the update applies to the concurrently changed Channel as well
+	err, ch := db.UpdateChannel(CHANNEL_UID, 0, streambot.ChannelPatch{Name: &NAME})
+	if err != nil || updates != 2 || ch.Version != 4 {
+		t.Fatalf("Expected update without version to be retried, given %v after %d updates", err, updates)
+	}
+}
+
+func TestListChannelsInGraph(t *testing.T) {
+	GRAPH 		:= "foobarbaz"
+	CHANNEL_UID := uuid.New()
+
+	// Set up a mock server to handle the listing script
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body := GremlinRequest(t, GRAPH, r)
+		if body.Params["prefix"] != "foo" || body.Params["field"] != "created_at" || 
+			body.Params["desc"] != true || body.Params["first"] != float64(4) || 
+			body.Params["last"] != float64(5) {
+			t.Fatalf("Unexpected listing script parameters %v", body.Params)
+		}
+		resFormat := "{\"results\":[{\"uid\":\"%s\",\"name\":\"Foo\",\"created_at\":42,\"_id\":1," +
+		"\"_type\":\"vertex\"}],\"success\":true,\"version\":\"2.4.0\",\"queryTime\":1.0}"
+		fmt.Fprintln(w, fmt.Sprintf(resFormat, CHANNEL_UID))
+	}
+	err, r, db := MockRexsterServerAndInstantiateGraphDatabase(t, GRAPH, handler)
+	defer r.Close()
+	if err != nil {
+		t.Fatalf("Unexpected error in MockRexsterServerAndInstantiateGraphDatabase: %v", err)
+	}
+	filter := streambot.ChannelFilter{"FOO"}
+	order := streambot.ChannelSort{streambot.SortChannelsByCreatedAt, true}
+	err, chs := db.ListChannels(filter, order, streambot.Page{4, 2})
+	if err != nil {
+		t.Fatalf("Unexpected error when listing Channels: %v", err)
+	}
+	if len(chs) != 1 || chs[0].Id != CHANNEL_UID || chs[0].CreatedAt != 42 {
+		t.Fatalf("Expected Channel `%s` to be listed, given %v", CHANNEL_UID, chs)
+	}
+}
+
 func TestSaveNewChannelSubscriptionInGraph(t *testing.T) {
 
 	GRAPH 				:= "foobarbaz"
 	FROM_CHANNEL_UID 	:= uuid.New()
-	FROM_CHANNEL_ID		:= rand.Intn(10000000000)
 	TO_CHANNEL_UID 		:= uuid.New()
-	TO_CHANNEL_ID		:= rand.Intn(10000000000)
 	TIME 				:= time.Now().Unix()
-	SUBSCRIPTION_ID 	:= uuid.New()
 
 	// Keep track on the server side being called up during test
 	serverCalled := false
 
 	// Set up a mock server to handle subscription edge creation request
 	handler := func(w http.ResponseWriter, r *http.Request) {
-        
-        // Verify URL is of expected shape
 		t.Logf("\nReceived request on %s\n", r.URL.String())
-        var res string
-        if r.URL.String() == fmt.Sprintf("/graphs/%s/vertices?key=uid&value=%s", GRAPH, FROM_CHANNEL_UID) {
-			// Return an empty string to gain a 200
-			resFormat := "{\"results\":[{\"uid\":\"%s\",\"_id\":%d,\"_type\":\"vertex\"}]," +
-			"\"success\":true,\"version\":\"2.4.0\",\"queryTime\":23.049033}"
-			res = fmt.Sprintf(resFormat, FROM_CHANNEL_UID, FROM_CHANNEL_ID)
-        } else if r.URL.String() == fmt.Sprintf("/graphs/%s/vertices?key=uid&value=%s", GRAPH, TO_CHANNEL_UID) {
-        	// Return an empty string to gain a 200
-			resFormat := "{\"results\":[{\"uid\":\"%s\",\"_id\":%d,\"_type\":\"vertex\"}]," +
-			"\"success\":true,\"version\":\"2.4.0\",\"queryTime\":23.049033}"
-			res = fmt.Sprintf(resFormat, TO_CHANNEL_UID, TO_CHANNEL_ID)
-        } else if r.URL.String() == fmt.Sprintf("/graphs/%s/edges/", GRAPH) {
-        	// Return an empty string to gain a 200
-			resFormat := "{\"results\":[{\"time\":%d,\"_id\":%d,\"_type\":\"edge\"," +
-			"\"_outV\":%d,\"_inV\":%d,\"_label\":\"subscribe\"}],\"success\":true,\"version\":" +
-			"\"2.4.0\",\"queryTime\":23.049033}"
-			res = fmt.Sprintf(resFormat, TIME, SUBSCRIPTION_ID, FROM_CHANNEL_ID, TO_CHANNEL_ID)
-    	} else {
-    		t.Fatalf("Unexpected request on URL %s", r.URL.String())
-    	}
-		fmt.Fprintln(w, res)
+		body := GremlinRequest(t, GRAPH, r)
+		expectedScript := "f=g.V(\"uid\",from);t=g.V(\"uid\",to);if(!f.hasNext()||!t.hasNext())" +
+		"{return false};f=f.next();t=t.next();if(!f.outE(\"subscribe\").inV.has(\"uid\",to).hasNext())" +
+		"{g.addEdge(f,t,\"subscribe\",[created_at:created_at])};true"
+		if body.Script != expectedScript {
+			t.Fatalf("Expected script `%s`, given `%s`", expectedScript, body.Script)
+		}
+		// Verify ids and creation time are bound as script parameters
+		if body.Params["from"] != FROM_CHANNEL_UID || body.Params["to"] != TO_CHANNEL_UID || 
+			body.Params["created_at"] != float64(TIME) {
+			t.Fatalf("Unexpected Channel subscription script parameters %v", body.Params)
+		}
+		fmt.Fprintln(w, "{\"results\":[true],\"success\":true,\"version\":\"2.4.0\",\"queryTime\":23.049033}")
 		// Switch tracker flag for server call
 		serverCalled = true
 	}
@@ -244,37 +403,88 @@ func TestSaveNewChannelSubscriptionInGraph(t *testing.T) {
 	}
 }
 
+func TestSaveRepeatedChannelSubscriptionInGraph(t *testing.T) {
+	GRAPH 				:= "foobarbaz"
+	FROM_CHANNEL_UID 	:= uuid.New()
+	TO_CHANNEL_UID 		:= uuid.New()
+
+	// Keep the creation times of the edges the way a graph would, adding an edge only unless the 
+	// script finds an existing one
+	var edges []float64
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body := GremlinRequest(t, GRAPH, r)
+		guarded := strings.Contains(body.Script, "f.outE(\"subscribe\").inV.has(\"uid\",to).hasNext()")
+		if !guarded || len(edges) == 0 {
+			edges = append(edges, body.Params["created_at"].(float64))
+		}
+		fmt.Fprintln(w, "{\"results\":[true],\"success\":true,\"version\":\"2.4.0\",\"queryTime\":1.0}")
+	}
+	err, r, db := MockRexsterServerAndInstantiateGraphDatabase(t, GRAPH, handler)
+	if err != nil {
+		t.Fatalf("Unexpected error in MockRexsterServerAndInstantiateGraphDatabase: %v", err)
+	}
+	defer r.Close()
+	for _, creationTime := range []int64{42, 43} {
+		if err = db.SaveChannelSubscription(FROM_CHANNEL_UID, TO_CHANNEL_UID, creationTime); err != nil {
+			t.Fatalf("Unexpected error when saving Channel subscription: %v", err)
+		}
+	}
+	if len(edges) != 1 || edges[0] != 42 {
+		t.Fatalf("Expected the first subscription to be kept only, given edges created at %v", edges)
+	}
+}
+
+func TestSaveChannelSubscriptionOfUnknownChannelInGraph(t *testing.T) {
+	GRAPH := "foobarbaz"
+
+	// Respond the way the subscription script does when a channel vertex is missing
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "{\"results\":[false],\"success\":true,\"version\":\"2.4.0\",\"queryTime\":1.0}")
+	}
+	err, r, db := MockRexsterServerAndInstantiateGraphDatabase(t, GRAPH, handler)
+	if err != nil {
+		t.Fatalf("Unexpected error in MockRexsterServerAndInstantiateGraphDatabase: %v", err)
+	}
+	defer r.Close()
+	err = db.SaveChannelSubscription(uuid.New(), uuid.New(), time.Now().Unix())
+	if !errors.Is(err, streambot.ErrChannelNotFound) {
+		t.Fatalf("Expected ErrChannelNotFound for unknown subscribing Channel, given %v", err)
+	}
+}
+
 func TestGetChannelSubscriptions(t *testing.T) {
 	GRAPH 					:= "foobarbar"
 	CHANNEL_ID 				:= uuid.New()
 	SUBSCRIBED_CHANNEL_ID 	:= uuid.New()
 	SUBSCRIBED_CHANNEL_NAME := "foobar"
+	SUBSCRIPTION_TIME 		:= time.Now().Unix()
 
 	// Keep track on the server side being called up during test
 	serverCalled := false
 
 	// Set up a mock server to handle subscription edge creation request
 	handler := func(w http.ResponseWriter, r *http.Request) {
-        // Verify request method is GET
-        if r.Method != "GET" {
-        	msgFormat := "Expected request method to be `GET`, given `%s`"
-			t.Fatalf(msgFormat, r.Method)
-        }
-        // Verify URL is of expected shape
-        expectedURL := fmt.Sprintf("/graphs/%s/tp/gremlin", GRAPH)
-        scriptFormat := "g.V(\"uid\",\"%s\").as('x').out.gather.scatter.loop('x'){it.loops < 5}{true}.dedup()"
-        script := fmt.Sprintf(scriptFormat, CHANNEL_ID)
-		q := url.Values{"script": []string{script}}
-		expectedURL = fmt.Sprintf("%s?%s", expectedURL, q.Encode())
-        if r.URL.String() != expectedURL {
-        	msgFormat := "Expected request URL to be `%s`, given `%s`"
-			t.Fatalf(msgFormat, expectedURL, r.URL.String())
-        }
 		t.Logf("Received request on %s", r.URL)
+		body := GremlinRequest(t, GRAPH, r)
+		// Verify script and the bound traversal and range parameters
+		expectedScript := "g.V(\"uid\",uid).as('x').outE(\"subscribe\").inV.loop('x'){it.loops < depth}" +
+		"{true}.path.transform{[uid:it[-1].uid,name:it[-1].name,description:it[-1].description," +
+		"owner_id:it[-1].owner_id,channel_created_at:it[-1].created_at,channel_updated_at:" +
+		"it[-1].updated_at,created_at:it[-2].created_at,hops:(it.size()-1).intdiv(2)]}" +
+		".filter{it.uid != uid}" +
+		".order{it.a.hops <=> it.b.hops ?: it.a.uid <=> it.b.uid}.dedup{it.uid}[first..last]"
+		if body.Script != expectedScript {
+			t.Fatalf("Expected script `%s`, given `%s`", expectedScript, body.Script)
+		}
+		if body.Params["uid"] != CHANNEL_ID || body.Params["depth"] != float64(3) || 
+			body.Params["first"] != float64(10) || body.Params["last"] != float64(29) {
+			t.Fatalf("Unexpected subscriptions script parameters %v", body.Params)
+		}
 		// Return an empty string to gain a 200
-		resFormat := "{\"results\":[{\"name\":\"%s\",\"uid\":\"%s\",\"_id\":8,\"_type\":" +
-		"\"vertex\"}],\"success\":true,\"version\":\"2.4.0\",\"queryTime\":29.266926}"
-		res := fmt.Sprintf(resFormat, SUBSCRIBED_CHANNEL_NAME, SUBSCRIBED_CHANNEL_ID)
+		rowFormat := "{\"name\":\"%s\",\"uid\":\"%s\",\"created_at\":%d,\"hops\":%d}"
+		row := fmt.Sprintf(rowFormat, SUBSCRIBED_CHANNEL_NAME, SUBSCRIBED_CHANNEL_ID, SUBSCRIPTION_TIME, 1)
+		resFormat := "{\"results\":[%s],\"success\":true,\"version\":\"2.4.0\",\"queryTime\":29.266926}"
+		res := fmt.Sprintf(resFormat, row)
 		fmt.Fprintln(w, res)
 		// Switch tracker flag for server call
 		serverCalled = true
@@ -285,18 +495,25 @@ func TestGetChannelSubscriptions(t *testing.T) {
 		t.Fatalf("Unexpected error in MockRexsterServerAndInstantiateGraphDatabase: %v", err)
 	}
 	// Save channel subscription in the graph database
-	err, channels := db.GetSubscriptionsForChannelWithUid(CHANNEL_ID)
+	err, subs := db.GetSubscriptionsForChannelWithUid(CHANNEL_ID, 3, streambot.Page{10, 20})
 	if err != nil {
 		t.Fatalf("Unexpected error %v", err)
 	}
-	fmt.Printf("Channels in test received %v", channels)
-	if channels == nil {
-		t.Fatalf("Unexpected empty Channels slice from GetSubscriptionsForChannelWithUid")
+	fmt.Printf("Subscriptions in test received %v", subs)
+	if subs == nil {
+		t.Fatalf("Unexpected empty Subscriptions slice from GetSubscriptionsForChannelWithUid")
 	}
-	if len(channels) != 1 {
-		t.Fatalf("Expected length of Channels slice 1, given %d", len(channels))	
+	if len(subs) != 1 {
+		t.Fatalf("Expected length of Subscriptions slice 1, given %d", len(subs))	
+	}
+	if subs[0].Hops != 1 {
+		t.Fatalf("Expected subscribed Channel to be reported at distance 1, given %d", subs[0].Hops)
 	}
-	ch := channels[0]
+	if subs[0].CreatedAt != SUBSCRIPTION_TIME {
+		errMsgFormat := "Expected subscription created at `%d`, given `%d`"
+		t.Fatalf(errMsgFormat, SUBSCRIPTION_TIME, subs[0].CreatedAt)
+	}
+	ch := subs[0].Channel
 	if ch.Id != SUBSCRIBED_CHANNEL_ID {
 		errMsgFormat := "Expected subscribed Channel with Id `%s`, given `%s`"
 		t.Fatalf(errMsgFormat, SUBSCRIBED_CHANNEL_ID, ch.Id)	
@@ -309,4 +526,469 @@ func TestGetChannelSubscriptions(t *testing.T) {
 	if !serverCalled {
 		t.Fatalf("Expected to have posted Channel subscription data to graph database server")
 	}
+}
+
+func TestDeleteChannelInGraph(t *testing.T) {
+	GRAPH 		:= "foobarbaz"
+	CHANNEL_UID := uuid.New()
+
+	// Keep track on the removal script being sent to the server during test
+	scriptCalled := false
+
+	// Set up a mock server to handle vertex lookup and removal requests
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		t.Logf("Received request on %s", r.URL)
+		var res string
+		if r.URL.String() == fmt.Sprintf("/graphs/%s/vertices?key=uid&value=%s", GRAPH, CHANNEL_UID) {
+			resFormat := "{\"results\":[{\"uid\":\"%s\",\"name\":\"foo\",\"_id\":%d,\"_type\":" +
+			"\"vertex\"}],\"success\":true,\"version\":\"2.4.0\",\"queryTime\":23.049033}"
+			res = fmt.Sprintf(resFormat, CHANNEL_UID, rand.Intn(10000000000))
+		} else if r.URL.Path == fmt.Sprintf("/graphs/%s/tp/gremlin", GRAPH) {
+			// Verify the script removes the channel's subscription edges and the vertex itself
+			body := GremlinRequest(t, GRAPH, r)
+			if body.Params["uid"] != CHANNEL_UID || 
+				!strings.Contains(body.Script, "bothE(\"subscribe\")") || 
+				!strings.Contains(body.Script, "g.removeVertex(v)") {
+				t.Fatalf("Unexpected Channel removal script `%s` with %v", body.Script, body.Params)
+			}
+			res = "{\"results\":[],\"success\":true,\"version\":\"2.4.0\",\"queryTime\":23.049033}"
+			scriptCalled = true
+		} else {
+			t.Fatalf("Unexpected request on URL %s", r.URL.String())
+		}
+		fmt.Fprintln(w, res)
+	}
+	err, r, db := MockRexsterServerAndInstantiateGraphDatabase(t, GRAPH, handler)
+	defer r.Close()
+	if err != nil {
+		t.Fatalf("Unexpected error in MockRexsterServerAndInstantiateGraphDatabase: %v", err)
+	}
+	err = db.DeleteChannel(CHANNEL_UID, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	// Verify server was called as expected
+	if !scriptCalled {
+		t.Fatalf("Expected to have sent Channel removal script to graph database server")
+	}
+}
+
+func TestDeleteUnknownChannelInGraph(t *testing.T) {
+	GRAPH 		:= "foobarbaz"
+	CHANNEL_UID := uuid.New()
+
+	// Set up a mock server that knows no vertices at all
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == fmt.Sprintf("/graphs/%s/tp/gremlin", GRAPH) {
+			t.Fatalf("Unexpected removal script for unknown Channel `%s`", r.URL.String())
+		}
+		fmt.Fprintln(w, "{\"results\":[],\"success\":true,\"version\":\"2.4.0\",\"queryTime\":1.0}")
+	}
+	err, r, db := MockRexsterServerAndInstantiateGraphDatabase(t, GRAPH, handler)
+	defer r.Close()
+	if err != nil {
+		t.Fatalf("Unexpected error in MockRexsterServerAndInstantiateGraphDatabase: %v", err)
+	}
+	err = db.DeleteChannel(CHANNEL_UID, 0)
+	if !errors.Is(err, streambot.ErrChannelNotFound) {
+		t.Fatalf("Expected ErrChannelNotFound when deleting unknown Channel, given %v", err)
+	}
+}
+
+func TestGetChannelWithDuplicateUidInGraph(t *testing.T) {
+	GRAPH 		:= "foobarbaz"
+	CHANNEL_UID := uuid.New()
+
+	// Set up a mock server that knows two vertices with the same uid
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		resFormat := "{\"version\":\"2.4.0\",\"results\":[{\"uid\":\"%s\",\"name\":\"foo\"," +
+		"\"_id\":1,\"_type\":\"vertex\"},{\"uid\":\"%s\",\"name\":\"bar\",\"_id\":2," +
+		"\"_type\":\"vertex\"}],\"totalSize\":2,\"queryTime\":1.0}"
+		fmt.Fprintln(w, fmt.Sprintf(resFormat, CHANNEL_UID, CHANNEL_UID))
+	}
+	err, r, db := MockRexsterServerAndInstantiateGraphDatabase(t, GRAPH, handler)
+	defer r.Close()
+	if err != nil {
+		t.Fatalf("Unexpected error in MockRexsterServerAndInstantiateGraphDatabase: %v", err)
+	}
+	err, _ = db.GetChannelWithUid(CHANNEL_UID)
+	if !errors.Is(err, streambot.ErrDuplicateUid) {
+		t.Fatalf("Expected ErrDuplicateUid for Channel with duplicate uid, given %v", err)
+	}
+}
+
+func TestErrorsMatchSentinelsByIdentity(t *testing.T) {
+	// An error of the same code is not the sentinel, unless it wraps the sentinel
+	internal := streambot.NewError(streambot.ErrorCodeInternal, "Channel index corrupted", nil)
+	if errors.Is(internal, streambot.ErrDuplicateUid) {
+		t.Fatalf("Expected internal error not to match ErrDuplicateUid")
+	}
+	duplicate := streambot.WrapError(streambot.ErrDuplicateUid, "Rexster backend returned 2 vertices")
+	if !errors.Is(duplicate, streambot.ErrDuplicateUid) {
+		t.Fatalf("Expected wrapped sentinel to match ErrDuplicateUid")
+	}
+	// Backend failures keep their cause, but not in the message shown to clients
+	cause := errors.New("dial tcp 10.0.0.1:8182: connection refused")
+	unavailable := streambot.NewBackendUnavailableError("Failed to query vertices at Rexster", cause)
+	if !errors.Is(unavailable, streambot.ErrBackendUnavailable) || !errors.Is(unavailable, cause) || 
+		strings.Contains(unavailable.Message, "10.0.0.1") {
+		t.Fatalf("Expected unavailable backend error to chain its cause, given %v", unavailable)
+	}
+	if code := streambot.ErrorCodeOf(duplicate); code != streambot.ErrorCodeInternal {
+		t.Fatalf("Expected code `%s` of wrapped sentinel, given `%s`", streambot.ErrorCodeInternal, code)
+	}
+}
+
+func TestGraphDatabaseReportsUnavailableBackend(t *testing.T) {
+	GRAPH := "foobarbaz"
+
+	// Take the mock server down right away, so that no Rexster host is reachable
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+	err, r, db := MockRexsterServerAndInstantiateGraphDatabase(t, GRAPH, handler)
+	if err != nil {
+		t.Fatalf("Unexpected error in MockRexsterServerAndInstantiateGraphDatabase: %v", err)
+	}
+	r.Close()
+	err, _ = db.GetSubscriptionsForChannelWithUid(uuid.New(), 1, streambot.Page{})
+	if !errors.Is(err, streambot.ErrBackendUnavailable) {
+		t.Fatalf("Expected ErrBackendUnavailable for unreachable Rexster, given %v", err)
+	}
+	if errors.Is(err, streambot.ErrChannelNotFound) {
+		t.Fatalf("Expected unreachable Rexster not to be reported as unknown Channel, given %v", err)
+	}
+}
+
+func TestGraphDatabaseSkipsUnavailableHost(t *testing.T) {
+	GRAPH := "foobarbaz"
+
+	// A proxy in front of the first host reports it unavailable, the second host responds
+	proxyCalls, rexsterCalls := 0, 0
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxyCalls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, "<html><body>503 Service Unavailable</body></html>")
+	}))
+	defer proxy.Close()
+	rexster := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rexsterCalls++
+		fmt.Fprintln(w, "{\"results\":[],\"success\":true,\"version\":\"2.4.0\",\"queryTime\":1.0}")
+	}))
+	defer rexster.Close()
+	hosts := []string{strings.Split(proxy.URL, "http://")[1], strings.Split(rexster.URL, "http://")[1]}
+	db, err := streambot.NewGraphDatabase(GRAPH, hosts)
+	if err != nil {
+		t.Fatalf("Unexpected error when creating graph database client: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		err, _ = db.GetSubscriptionsForChannelWithUid(uuid.New(), 1, streambot.Page{})
+		if err != nil {
+			t.Fatalf("Expected unavailable host to be skipped, given %v", err)
+		}
+	}
+	// The failed host is not tried again until it is reanimated
+	if proxyCalls != 1 || rexsterCalls != 2 {
+		t.Fatalf("Expected 1 call to the failed host and 2 to the other, given %d and %d", 
+			proxyCalls, rexsterCalls)
+	}
+}
+
+func TestGraphDatabaseDoesNotRetryChangesAtOtherHost(t *testing.T) {
+	GRAPH := "foobarbaz"
+
+	// The first host takes the script but fails to respond, it may have applied the change already
+	failingCalls, rexsterCalls := 0, 0
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failingCalls++
+		w.WriteHeader(http.StatusGatewayTimeout)
+	}))
+	defer failing.Close()
+	rexster := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rexsterCalls++
+		fmt.Fprintln(w, "{\"results\":[true],\"success\":true,\"version\":\"2.4.0\",\"queryTime\":1.0}")
+	}))
+	defer rexster.Close()
+	hosts := []string{strings.Split(failing.URL, "http://")[1], strings.Split(rexster.URL, "http://")[1]}
+	db, err := streambot.NewGraphDatabase(GRAPH, hosts)
+	if err != nil {
+		t.Fatalf("Unexpected error when creating graph database client: %v", err)
+	}
+	err = db.SaveChannelSubscription(uuid.New(), uuid.New(), 42)
+	if !errors.Is(err, streambot.ErrBackendUnavailable) || failingCalls != 1 || rexsterCalls != 0 {
+		t.Fatalf("Expected subscription not to be retried at other host, given %v", err)
+	}
+	// A host that cannot be connected to never received the script, so the next one gets it
+	failing.Close()
+	db, err = streambot.NewGraphDatabase(GRAPH, hosts)
+	if err != nil {
+		t.Fatalf("Unexpected error when creating graph database client: %v", err)
+	}
+	if err = db.SaveChannelSubscription(uuid.New(), uuid.New(), 42); err != nil || rexsterCalls != 1 {
+		t.Fatalf("Expected subscription to be sent to the reachable host, given %v", err)
+	}
+}
+
+func TestGraphDatabaseReportsScriptFailure(t *testing.T) {
+	GRAPH := "foobarbaz"
+
+	// Respond the way Rexster does when a script fails to evaluate
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintln(w, "{\"message\":\"\",\"error\":\"javax.script.ScriptException: " + 
+			"java.util.NoSuchElementException\",\"api\":{},\"version\":\"2.4.0\"}")
+	}
+	err, r, db := MockRexsterServerAndInstantiateGraphDatabase(t, GRAPH, handler)
+	if err != nil {
+		t.Fatalf("Unexpected error in MockRexsterServerAndInstantiateGraphDatabase: %v", err)
+	}
+	defer r.Close()
+	err, _ = db.GetSubscriptionsForChannelWithUid(uuid.New(), 1, streambot.Page{})
+	if err == nil || errors.Is(err, streambot.ErrBackendUnavailable) {
+		t.Fatalf("Expected failed script not to be reported as unavailable backend, given %v", err)
+	}
+}
+
+func TestDeleteChannelSubscriptionInGraph(t *testing.T) {
+	GRAPH 				:= "foobarbaz"
+	FROM_CHANNEL_UID 	:= uuid.New()
+	TO_CHANNEL_UID 		:= uuid.New()
+
+	// Keep track on the server side being called up during test
+	serverCalled := false
+
+	// Set up a mock server to handle subscription edge removal request
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		t.Logf("Received request on %s", r.URL)
+		// Verify the script only removes the subscription edge between both channels
+		body := GremlinRequest(t, GRAPH, r)
+		if body.Params["from"] != FROM_CHANNEL_UID || body.Params["to"] != TO_CHANNEL_UID || 
+			!strings.Contains(body.Script, "g.removeEdge(it)") {
+			t.Fatalf("Unexpected Channel subscription removal script `%s` with %v", body.Script, body.Params)
+		}
+		// Respond with no results, as the edge might have been removed before
+		fmt.Fprintln(w, "{\"results\":[],\"success\":true,\"version\":\"2.4.0\",\"queryTime\":1.0}")
+		serverCalled = true
+	}
+	err, r, db := MockRexsterServerAndInstantiateGraphDatabase(t, GRAPH, handler)
+	defer r.Close()
+	if err != nil {
+		t.Fatalf("Unexpected error in MockRexsterServerAndInstantiateGraphDatabase: %v", err)
+	}
+	// Remove the subscription twice to verify the removal is idempotent
+	for i := 0; i < 2; i++ {
+		err = db.DeleteChannelSubscription(FROM_CHANNEL_UID, TO_CHANNEL_UID)
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+	}
+	// Verify server was called as expected
+	if !serverCalled {
+		t.Fatalf("Expected to have sent Channel subscription removal script to graph database server")
+	}
+}
+
+func TestGetChannelSubscribers(t *testing.T) {
+	GRAPH 					:= "foobarbar"
+	CHANNEL_ID 				:= uuid.New()
+	SUBSCRIBER_CHANNEL_ID 	:= uuid.New()
+	SUBSCRIBER_CHANNEL_NAME := "foobar"
+
+	// Keep track on the server side being called up during test
+	serverCalled := false
+
+	// Set up a mock server to handle the reverse subscription lookup
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		t.Logf("Received request on %s", r.URL)
+		// Verify the script follows incoming subscription edges only
+		body := GremlinRequest(t, GRAPH, r)
+		expectedScript := "g.V(\"uid\",uid).in(\"subscribe\").dedup()"
+		if body.Script != expectedScript || body.Params["uid"] != CHANNEL_ID {
+			t.Fatalf("Expected script `%s`, given `%s` with %v", expectedScript, body.Script, body.Params)
+		}
+		resFormat := "{\"results\":[{\"name\":\"%s\",\"uid\":\"%s\",\"_id\":8,\"_type\":" +
+		"\"vertex\"}],\"success\":true,\"version\":\"2.4.0\",\"queryTime\":29.266926}"
+		fmt.Fprintln(w, fmt.Sprintf(resFormat, SUBSCRIBER_CHANNEL_NAME, SUBSCRIBER_CHANNEL_ID))
+		serverCalled = true
+	}
+	err, r, db := MockRexsterServerAndInstantiateGraphDatabase(t, GRAPH, handler)
+	defer r.Close()
+	if err != nil {
+		t.Fatalf("Unexpected error in MockRexsterServerAndInstantiateGraphDatabase: %v", err)
+	}
+	err, channels := db.GetSubscribersForChannelWithUid(CHANNEL_ID)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if len(channels) != 1 {
+		t.Fatalf("Expected length of Channels slice 1, given %d", len(channels))
+	}
+	if channels[0].Id != SUBSCRIBER_CHANNEL_ID || channels[0].Name != SUBSCRIBER_CHANNEL_NAME {
+		errMsgFormat := "Expected subscribing Channel with Id `%s` and name `%s`, given `%v`"
+		t.Fatalf(errMsgFormat, SUBSCRIBER_CHANNEL_ID, SUBSCRIBER_CHANNEL_NAME, channels[0])
+	}
+	// Verify server was called as expected
+	if !serverCalled {
+		t.Fatalf("Expected to have queried Channel subscribers at graph database server")
+	}
+}
+
+func TestGremlinScriptsTreatHostileIdsAsLiteralData(t *testing.T) {
+	GRAPH 		:= "foobarbaz"
+	// Ids trying to break out of a string literal and run arbitrary Groovy at the Rexster server
+	HOSTILE_IDS := []string{
+		"\");g.V.each{g.removeVertex(it)};(\"",
+		"');g.clear();('",
+		"${g.clear()}",
+		"\\\"+System.exit(0)+\"",
+	}
+
+	// Keep track on the scripts and parameters received by the server
+	var requests []GremlinRequestBody
+
+	// Set up a mock server capturing every Gremlin script
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, GremlinRequest(t, GRAPH, r))
+		fmt.Fprintln(w, "{\"results\":[],\"success\":true,\"version\":\"2.4.0\",\"queryTime\":1.0}")
+	}
+	err, r, db := MockRexsterServerAndInstantiateGraphDatabase(t, GRAPH, handler)
+	defer r.Close()
+	if err != nil {
+		t.Fatalf("Unexpected error in MockRexsterServerAndInstantiateGraphDatabase: %v", err)
+	}
+	for _, id := range HOSTILE_IDS {
+		requests = nil
+		if err = db.SaveChannelSubscription(id, id, 0); err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		if err = db.DeleteChannelSubscription(id, id); err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		if err, _ = db.GetSubscriptionsForChannelWithUid(id, 1, streambot.Page{}); err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		if err, _ = db.GetSubscribersForChannelWithUid(id); err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		if len(requests) != 4 {
+			t.Fatalf("Expected 4 Gremlin scripts to be evaluated, given %d", len(requests))
+		}
+		// Verify the hostile id never made it into a script but arrived unaltered as parameter
+		for _, req := range requests {
+			if strings.Contains(req.Script, id) {
+				t.Fatalf("Expected id `%s` not to be part of script `%s`", id, req.Script)
+			}
+			bound := false
+			for _, value := range req.Params {
+				if value == id {
+					bound = true
+				}
+			}
+			if !bound {
+				t.Fatalf("Expected id `%s` to be bound as literal parameter, given %v", id, req.Params)
+			}
+		}
+	}
+}
+
+func TestCheckConsistencyInGraph(t *testing.T) {
+	GRAPH 		:= "foobarbaz"
+	CHANNEL_UID := uuid.New()
+
+	// Set up a mock server that knows one duplicated uid and one dangling subscription
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body := GremlinRequest(t, GRAPH, r)
+		if strings.Contains(body.Script, "g.V.has(\"uid\")") {
+			resFormat := "{\"results\":[{\"uid\":\"%s\",\"ids\":[\"4\",\"8\"]}],\"success\":true}"
+			fmt.Fprintln(w, fmt.Sprintf(resFormat, CHANNEL_UID))
+		} else if strings.Contains(body.Script, "g.E.has(\"label\",\"subscribe\")") {
+			resFormat := "{\"results\":[{\"id\":\"15\",\"from\":\"%s\",\"to\":null}],\"success\":true}"
+			fmt.Fprintln(w, fmt.Sprintf(resFormat, CHANNEL_UID))
+		} else {
+			t.Fatalf("Unexpected consistency check script `%s`", body.Script)
+		}
+	}
+	err, r, db := MockRexsterServerAndInstantiateGraphDatabase(t, GRAPH, handler)
+	defer r.Close()
+	if err != nil {
+		t.Fatalf("Unexpected error in MockRexsterServerAndInstantiateGraphDatabase: %v", err)
+	}
+	err, report := db.CheckConsistency()
+	if err != nil {
+		t.Fatalf("Unexpected error when checking consistency: %v", err)
+	}
+	if report.Consistent() || len(report.DuplicateUids) != 1 || 
+		report.DuplicateUids[0].Uid != CHANNEL_UID || len(report.DuplicateUids[0].VertexIds) != 2 {
+		t.Fatalf("Expected duplicate uid `%s` on 2 vertices to be reported, given %v", CHANNEL_UID, report)
+	}
+	expected := streambot.DanglingSubscription{"15", CHANNEL_UID, ""}
+	if len(report.DanglingSubscriptions) != 1 || report.DanglingSubscriptions[0] != expected {
+		t.Fatalf("Expected dangling subscription %v to be reported, given %v", expected, report)
+	}
+}
+
+func TestMergeDuplicateUidInGraph(t *testing.T) {
+	GRAPH 		:= "foobarbaz"
+	CHANNEL_UID := uuid.New()
+
+	// Set up a mock server to list the duplicate vertices and to apply the merge of them
+	merged := false
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body := GremlinRequest(t, GRAPH, r)
+		if body.Params["uid"] == CHANNEL_UID {
+			// Vertex `8` is more recent, its duplicate `3` has a subscription edge stored before 
+			// subscriptions were timed
+			fmt.Fprintln(w, "{\"results\":[{\"id\":\"3\",\"version\":1,\"updated_at\":0,\"out\":" +
+			"[{\"id\":\"e1\",\"vertex_id\":\"5\",\"properties\":{}}],\"in\":[]},{\"id\":\"8\"," +
+			"\"version\":2,\"updated_at\":0,\"out\":[],\"in\":[]}],\"success\":true}")
+			return
+		}
+		var plan streambot.MergePlan
+		raw, _ := body.Params["plan"].(string)
+		if err := json.Unmarshal([]byte(raw), &plan); err != nil {
+			t.Fatalf("Unexpected merge script `%s` with %v", body.Script, body.Params)
+		}
+		if plan.SurvivorId != "8" || len(plan.AddEdges) != 1 || len(plan.AddEdges[0].Properties) != 0 || 
+			len(plan.RemoveEdges) != 1 || len(plan.RemoveVertices) != 1 || plan.RemoveVertices[0] != "3" {
+			t.Fatalf("Unexpected merge plan %v", plan)
+		}
+		merged = true
+		fmt.Fprintln(w, "{\"results\":[\"8\"],\"success\":true}")
+	}
+	err, r, db := MockRexsterServerAndInstantiateGraphDatabase(t, GRAPH, handler)
+	defer r.Close()
+	if err != nil {
+		t.Fatalf("Unexpected error in MockRexsterServerAndInstantiateGraphDatabase: %v", err)
+	}
+	err, survivorId := db.MergeDuplicateUid(CHANNEL_UID)
+	if err != nil || survivorId != "8" || !merged {
+		t.Fatalf("Expected vertex `8` to survive merge, given `%s` and error %v", survivorId, err)
+	}
+}
+
+func TestPlanMergeOfDuplicateVertices(t *testing.T) {
+	created := map[string]interface{}{"created_at": float64(42)}
+	vs := []streambot.DuplicateVertex{
+		// Subscribed to `a` and `b`, subscribed by `c`, lacks the creation time on the edge to `b`
+		{"1", 2, 10, []streambot.VertexEdge{{"e1", "a", created}, {"e2", "b", map[string]interface{}{}}}, 
+			[]streambot.VertexEdge{{"e3", "c", created}, {"e4", "3", created}}},
+		// Survives, as it has the same version as `1` but was updated later
+		{"2", 2, 20, []streambot.VertexEdge{{"e5", "a", created}}, nil},
+		// Outdated, subscribed to its duplicate `1`
+		{"3", 1, 30, []streambot.VertexEdge{{"e4", "1", created}}, nil},
+	}
+	plan := streambot.PlanMerge(vs)
+	if plan.SurvivorId != "2" {
+		t.Fatalf("Expected vertex `2` to survive merge, given `%s`", plan.SurvivorId)
+	}
+	// The subscription to `a` is already held by the survivor and the one between duplicates is 
+	// dropped, the others move with exactly the properties they have
+	expected := []streambot.PlannedEdge{{"2", "b", map[string]interface{}{}}, {"c", "2", created}}
+	if !reflect.DeepEqual(plan.AddEdges, expected) {
+		t.Fatalf("Expected edges %v to be added, given %v", expected, plan.AddEdges)
+	}
+	if !reflect.DeepEqual(plan.RemoveEdges, []string{"e1", "e2", "e3", "e4"}) {
+		t.Fatalf("Expected all edges of merged vertices to be removed once, given %v", plan.RemoveEdges)
+	}
+	if !reflect.DeepEqual(plan.RemoveVertices, []string{"1", "3"}) {
+		t.Fatalf("Expected vertices `1` and `3` to be removed, given %v", plan.RemoveVertices)
+	}
 }
\ No newline at end of file
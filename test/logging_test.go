@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+	"net/http"
+	"encoding/json"
+	"bytes"
+	"errors"
+	"regexp"
+	"time"
+	"fmt"
+	"github.com/op/go-logging"
+	"../src/streambot"
+)
+
+func TestLogEntryAppendsFieldsInOrder(t *testing.T) {
+	logger := streambot.NewLogger(streambot.Fields{"route": "GET /channels/:id", "request_id": "abc"})
+	logger = logger.WithError(errors.New("boom"))
+	entry := streambot.LogEntry{"Handled request", logger.Fields}
+	expected := "Handled request error=boom request_id=abc route=GET /channels/:id"
+	if entry.String() != expected {
+		t.Fatalf("Expected log entry `%s`, given `%s`", expected, entry.String())
+	}
+	// A nil Logger logs without fields
+	var none *streambot.Logger
+	if fields := none.With(streambot.Fields{"a": 1}).Fields; len(fields) != 1 {
+		t.Fatalf("Expected single field, given %v", fields)
+	}
+}
+
+func TestJSONFormatterWritesEntryFields(t *testing.T) {
+	entry := streambot.LogEntry{"Handled request", streambot.Fields{
+		"request_id": "abc", 
+		"duration_ms": 1.5, 
+		"message": "ignored",
+	}}
+	record := &logging.Record{
+		Time: time.Date(2015, 3, 1, 12, 0, 0, 0, time.UTC), 
+		Module: "streambot-api", 
+		Level: logging.INFO, 
+		Args: []interface{}{entry},
+	}
+	var buf bytes.Buffer
+	if err := (streambot.JSONFormatter{}).Format(0, record, &buf); err != nil {
+		t.Fatalf("Unexpected error when formatting record: %v", err)
+	}
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("Expected a JSON log line, given `%s`: %v", buf.String(), err)
+	}
+	if line["message"] != "Handled request" || line["request_id"] != "abc" || line["duration_ms"] != 1.5 || 
+		line["module"] != "streambot-api" || line["time"] != "2015-03-01T12:00:00Z" {
+		t.Fatalf("Unexpected JSON log line `%s`", buf.String())
+	}
+	if _, ok := line["level"]; !ok {
+		t.Fatalf("Expected level in JSON log line `%s`", buf.String())
+	}
+}
+
+// Remembers the fields of the Logger the API scoped the database with
+type ScopedDatabaseMock struct {
+	DatabaseMock
+	Fields streambot.Fields
+}
+
+func (db *ScopedDatabaseMock) WithLogger(logger *streambot.Logger) streambot.Database {
+	db.Fields = logger.Fields
+	return db
+}
+
+func TestAPICorrelatesRequestsById(t *testing.T) {
+	db := new(ScopedDatabaseMock)
+	a := streambot.NewAPI(db, nil)
+	errChan := make(chan error)
+	a.Serve(8098, "/v1/", errChan)
+	go func() {
+		t.Fatalf("Unexpected error occurred when starting API: %v", <- errChan)
+	}()
+	req, err := http.NewRequest("GET", "http://localhost:8098/v1/channels/foobar", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error when creating GET request: %v", err)
+	}
+	req.Header.Set("X-Request-Id", "client-id-1")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Unexpected error on executing GET request: %v", err)
+	}
+	res.Body.Close()
+	if res.Header.Get("X-Request-Id") != "client-id-1" {
+		t.Fatalf("Expected client request id to be responded, given `%s`", res.Header.Get("X-Request-Id"))
+	}
+	// The database logs with the fields of the request
+	if db.Fields["request_id"] != "client-id-1" || db.Fields["channel_id"] != "foobar" || 
+		db.Fields["route"] != "GET /channels/:id" {
+		t.Fatalf("Expected database to be scoped to the request, given fields %v", db.Fields)
+	}
+	// Request ids which could garble log lines are replaced
+	req.Header.Set("X-Request-Id", "forged\" id")
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Unexpected error on executing GET request: %v", err)
+	}
+	res.Body.Close()
+	id := res.Header.Get("X-Request-Id")
+	if matched, _ := regexp.MatchString(UUID_FORMAT, id); !matched {
+		t.Fatalf("Expected generated request id, given `%s`", id)
+	}
+	a.Shutdown()
+	<- a.Closed
+	fmt.Println("Done")
+}
\ No newline at end of file
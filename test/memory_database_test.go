@@ -0,0 +1,191 @@
+package main
+
+import (
+	"testing"
+	"../src/streambot"
+	"code.google.com/p/go-uuid/uuid"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+func SubscriptionIds(subs []streambot.Subscription) (ids []string) {
+	for _, sub := range subs {
+		ids = append(ids, sub.Channel.Id)
+	}
+	return
+}
+
+func ChannelIds(chs []streambot.Channel) (ids []string) {
+	for _, ch := range chs {
+		ids = append(ids, ch.Id)
+	}
+	return
+}
+
+// Verifies the graph semantics every Database implementation is expected to provide
+func VerifyDatabaseGraphSemantics(t *testing.T, db streambot.Database) {
+	// Create channels with ascending uids to have a predictable order within equal distances
+	var chs []*streambot.Channel
+	for i := 0; i < 4; i++ {
+		ch := streambot.NewChannel(fmt.Sprintf("channel-%d", i))
+		ch.Id = fmt.Sprintf("%d-%s", i, ch.Id)
+		ch.Description = fmt.Sprintf("Channel number %d", i)
+		ch.OwnerId = uuid.New()
+		chs = append(chs, ch)
+		if err := db.SaveChannel(ch); err != nil {
+			t.Fatalf("Unexpected error when saving Channel: %v", err)
+		}
+	}
+	a, b, c, d := chs[0].Id, chs[1].Id, chs[2].Id, chs[3].Id
+	err, ch := db.GetChannelWithUid(b)
+	if err != nil || ch == nil || *ch != *chs[1] {
+		t.Fatalf("Expected to retrieve Channel %v, given %v with error %v", chs[1], ch, err)
+	}
+	err, ch = db.GetChannelWithUid(uuid.New())
+	if !errors.Is(err, streambot.ErrChannelNotFound) {
+		t.Fatalf("Expected ErrChannelNotFound for unknown Channel, given %v", err)
+	}
+	err = db.SaveChannelSubscription(a, uuid.New(), 1)
+	if !errors.Is(err, streambot.ErrChannelNotFound) {
+		t.Fatalf("Expected ErrChannelNotFound when subscribing to unknown Channel, given %v", err)
+	}
+	// Verify listing Channels filtered by name prefix, ignoring case, in both directions
+	err, listed := db.ListChannels(streambot.ChannelFilter{"CHANNEL-"}, streambot.ChannelSort{}, streambot.Page{})
+	if err != nil || fmt.Sprint(ChannelIds(listed)) != fmt.Sprint([]string{a, b, c, d}) {
+		t.Fatalf("Expected Channels %v to be listed, given %v with error %v", []string{a, b, c, d}, listed, err)
+	}
+	order := streambot.ChannelSort{streambot.SortChannelsByName, true}
+	err, listed = db.ListChannels(streambot.ChannelFilter{}, order, streambot.Page{1, 2})
+	if err != nil || fmt.Sprint(ChannelIds(listed)) != fmt.Sprint([]string{c, b}) {
+		t.Fatalf("Expected Channels %v to be listed, given %v with error %v", []string{c, b}, listed, err)
+	}
+	err, listed = db.ListChannels(streambot.ChannelFilter{"channel_"}, streambot.ChannelSort{}, streambot.Page{})
+	if err != nil || len(listed) != 0 {
+		t.Fatalf("Expected no Channels to match a literal `_`, given %v with error %v", listed, err)
+	}
+	// Verify patching a Channel changes the given fields only
+	name, description := "renamed", ""
+	err, ch = db.UpdateChannel(d, 1, streambot.ChannelPatch{Name: &name, Description: &description})
+	if err != nil || ch.Name != name || ch.Description != "" || ch.OwnerId != chs[3].OwnerId {
+		t.Fatalf("Expected Channel `%s` to be renamed, given %v with error %v", d, ch, err)
+	}
+	err, ch = db.GetChannelWithUid(d)
+	if err != nil || ch.Name != name || ch.UpdatedAt < chs[3].UpdatedAt || ch.Version != 2 {
+		t.Fatalf("Expected renamed Channel `%s` at version 2 to be stored, given %v with error %v", d, ch, err)
+	}
+	chs[3] = ch
+	err, _ = db.UpdateChannel(uuid.New(), 0, streambot.ChannelPatch{Name: &name})
+	if !errors.Is(err, streambot.ErrChannelNotFound) {
+		t.Fatalf("Expected ErrChannelNotFound when updating unknown Channel, given %v", err)
+	}
+	// Verify changes to an outdated version are refused
+	err, _ = db.UpdateChannel(d, 1, streambot.ChannelPatch{Name: &name})
+	if !errors.Is(err, streambot.ErrVersionMismatch) {
+		t.Fatalf("Expected ErrVersionMismatch when updating outdated Channel, given %v", err)
+	}
+	if err = db.DeleteChannel(d, 1); !errors.Is(err, streambot.ErrVersionMismatch) {
+		t.Fatalf("Expected ErrVersionMismatch when deleting outdated Channel, given %v", err)
+	}
+	// Subscribe a -> b, a -> c, b -> c, c -> d and close the cycle with d -> a
+	edges := [][2]string{{a, b}, {a, c}, {b, c}, {c, d}, {d, a}}
+	for i, edge := range edges {
+		if err = db.SaveChannelSubscription(edge[0], edge[1], int64(i + 1)); err != nil {
+			t.Fatalf("Unexpected error when saving Channel subscription %v: %v", edge, err)
+		}
+	}
+	// Verify direct subscriptions only by default depth
+	err, subs := db.GetSubscriptionsForChannelWithUid(a, 1, streambot.Page{})
+	if err != nil {
+		t.Fatalf("Unexpected error when fetching subscriptions: %v", err)
+	}
+	if fmt.Sprint(SubscriptionIds(subs)) != fmt.Sprint([]string{b, c}) {
+		t.Fatalf("Expected direct subscriptions %v, given %v", []string{b, c}, SubscriptionIds(subs))
+	}
+	if subs[1].CreatedAt != 2 || subs[1].Hops != 1 || subs[1].Channel != *chs[2] {
+		t.Fatalf("Expected direct subscription to %v created at 2 with 1 hop, given %v", chs[2], subs[1])
+	}
+	// Verify transitive subscriptions are deduplicated, reported with shortest distance, and do 
+	// not contain the subscribing channel itself
+	err, subs = db.GetSubscriptionsForChannelWithUid(a, 5, streambot.Page{})
+	if err != nil {
+		t.Fatalf("Unexpected error when fetching subscriptions: %v", err)
+	}
+	if fmt.Sprint(SubscriptionIds(subs)) != fmt.Sprint([]string{b, c, d}) {
+		t.Fatalf("Expected transitive subscriptions %v, given %v", []string{b, c, d}, SubscriptionIds(subs))
+	}
+	if subs[2].Hops != 2 || subs[2].CreatedAt != 4 {
+		t.Fatalf("Expected transitive subscription created at 4 with 2 hops, given %v", subs[2])
+	}
+	// Verify paging through the subscriptions
+	err, subs = db.GetSubscriptionsForChannelWithUid(a, 5, streambot.Page{1, 1})
+	if err != nil || fmt.Sprint(SubscriptionIds(subs)) != fmt.Sprint([]string{c}) {
+		t.Fatalf("Expected second page to contain %s, given %v with error %v", c, subs, err)
+	}
+	err, subs = db.GetSubscriptionsForChannelWithUid(a, 5, streambot.Page{3, 1})
+	if err != nil || len(subs) != 0 {
+		t.Fatalf("Expected page behind the last subscription to be empty, given %v with error %v", subs, err)
+	}
+	// Verify direct subscribers
+	err, subscribers := db.GetSubscribersForChannelWithUid(c)
+	if err != nil || fmt.Sprint(ChannelIds(subscribers)) != fmt.Sprint([]string{a, b}) {
+		t.Fatalf("Expected subscribers %v, given %v with error %v", []string{a, b}, subscribers, err)
+	}
+	// Verify unsubscribing is idempotent
+	for i := 0; i < 2; i++ {
+		if err = db.DeleteChannelSubscription(a, b); err != nil {
+			t.Fatalf("Unexpected error when deleting Channel subscription: %v", err)
+		}
+	}
+	err, subs = db.GetSubscriptionsForChannelWithUid(a, 1, streambot.Page{})
+	if err != nil || fmt.Sprint(SubscriptionIds(subs)) != fmt.Sprint([]string{c}) {
+		t.Fatalf("Expected remaining subscription %s, given %v with error %v", c, subs, err)
+	}
+	// Verify deleting a channel removes all of its subscription edges
+	if err = db.DeleteChannel(c, 1); err != nil {
+		t.Fatalf("Unexpected error when deleting Channel: %v", err)
+	}
+	if err = db.DeleteChannel(c, 0); !errors.Is(err, streambot.ErrChannelNotFound) {
+		t.Fatalf("Expected ErrChannelNotFound when deleting Channel twice, given %v", err)
+	}
+	err, subs = db.GetSubscriptionsForChannelWithUid(a, 5, streambot.Page{})
+	if err != nil || len(subs) != 0 {
+		t.Fatalf("Expected no subscriptions after deleting Channel, given %v with error %v", subs, err)
+	}
+	err, subscribers = db.GetSubscribersForChannelWithUid(d)
+	if err != nil || len(subscribers) != 0 {
+		t.Fatalf("Expected no subscribers after deleting Channel, given %v with error %v", subscribers, err)
+	}
+}
+
+func TestMemoryDatabaseGraphSemantics(t *testing.T) {
+	VerifyDatabaseGraphSemantics(t, streambot.NewMemoryDatabase())
+}
+
+func TestMemoryDatabaseConcurrentAccess(t *testing.T) {
+	db := streambot.NewMemoryDatabase()
+	hub := streambot.NewChannel("hub")
+	db.SaveChannel(hub)
+	// Concurrently create channels subscribing to the hub while reading the hub's subscribers
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			ch := streambot.NewChannel(fmt.Sprintf("channel-%d", i))
+			db.SaveChannel(ch)
+			if err := db.SaveChannelSubscription(ch.Id, hub.Id, int64(i)); err != nil {
+				t.Errorf("Unexpected error when saving Channel subscription: %v", err)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			db.GetSubscribersForChannelWithUid(hub.Id)
+		}()
+	}
+	wg.Wait()
+	err, subscribers := db.GetSubscribersForChannelWithUid(hub.Id)
+	if err != nil || len(subscribers) != 50 {
+		t.Fatalf("Expected 50 subscribers, given %d with error %v", len(subscribers), err)
+	}
+}
\ No newline at end of file
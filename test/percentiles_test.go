@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+	"time"
+	"../src/streambot"
+)
+
+func TestPercentileSinkSummarizesWindow(t *testing.T) {
+	sink := streambot.NewPercentileSink()
+	method := []streambot.Label{{"method", "GetChannelWithUid"}}
+	for i := 100; i > 0; i-- {
+		sink.Time("db", time.Duration(i) * time.Millisecond, method)
+	}
+	sink.Time("http.requests", 250 * time.Microsecond, nil)
+	summaries := sink.Summarize()
+	expected := []streambot.LatencySummary{
+		{"db.GetChannelWithUid", 100, 50 * time.Millisecond, 95 * time.Millisecond, 99 * time.Millisecond},
+		{"http.requests", 1, 250 * time.Microsecond, 250 * time.Microsecond, 250 * time.Microsecond},
+	}
+	if len(summaries) != 2 || summaries[0] != expected[0] || summaries[1] != expected[1] {
+		t.Fatalf("Expected summaries %v, given %v", expected, summaries)
+	}
+	// Every window starts out empty
+	if summaries := sink.Summarize(); len(summaries) != 0 {
+		t.Fatalf("Expected no summaries for empty window, given %v", summaries)
+	}
+}
+
+func TestPercentileSinkBoundsSamples(t *testing.T) {
+	sink := streambot.NewPercentileSink()
+	sink.MaxSamples = 10
+	for i := 1; i <= 1000; i++ {
+		sink.Time("db", time.Duration(i) * time.Millisecond, nil)
+	}
+	summaries := sink.Summarize()
+	if len(summaries) != 1 || summaries[0].Count != 1000 || summaries[0].P50 > summaries[0].P99 || 
+		summaries[0].P99 > time.Second {
+		t.Fatalf("Expected summary of 1000 calls from the samples, given %v", summaries)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"testing"
+	"net/http"
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"fmt"
+	"time"
+	"../src/streambot"
+)
+
+func TestPrometheusRegistryExposesCountersAndHistograms(t *testing.T) {
+	registry := streambot.NewPrometheusRegistry("streambot")
+	method := streambot.Label{"method", "GetChannelWithUid"}
+	registry.Count("channels.get", nil)
+	registry.Count("channels.get", nil)
+	registry.Time("db", 3 * time.Millisecond, []streambot.Label{method})
+	registry.Time("db", 700 * time.Millisecond, []streambot.Label{method})
+	registry.Count("http.requests", []streambot.Label{{"route", "GET /channels/:id"}, {"status", "404"}})
+	var buf bytes.Buffer
+	registry.Expose(&buf)
+	out := buf.String()
+	expected := []string{
+		"# TYPE streambot_channels_get_total counter\nstreambot_channels_get_total 2\n",
+		"streambot_http_requests_total{route=\"GET /channels/:id\",status=\"404\"} 1\n",
+		"# TYPE streambot_db_duration_seconds histogram\n",
+		"streambot_db_duration_seconds_bucket{method=\"GetChannelWithUid\",le=\"0.001\"} 0\n",
+		"streambot_db_duration_seconds_bucket{method=\"GetChannelWithUid\",le=\"0.005\"} 1\n",
+		"streambot_db_duration_seconds_bucket{method=\"GetChannelWithUid\",le=\"0.5\"} 1\n",
+		"streambot_db_duration_seconds_bucket{method=\"GetChannelWithUid\",le=\"1\"} 2\n",
+		"streambot_db_duration_seconds_bucket{method=\"GetChannelWithUid\",le=\"+Inf\"} 2\n",
+		"streambot_db_duration_seconds_sum{method=\"GetChannelWithUid\"} 0.703\n",
+		"streambot_db_duration_seconds_count{method=\"GetChannelWithUid\"} 2\n",
+	}
+	for _, line := range expected {
+		if !strings.Contains(out, line) {
+			t.Fatalf("Expected exposition to contain `%s`, given:\n%s", line, out)
+		}
+	}
+}
+
+func TestRouteLabel(t *testing.T) {
+	expected := map[string]string{
+		"GET /v1/channels": "GET /channels",
+		"GET /v1/channels/search": "GET /channels/search",
+		"PATCH /v1/channels/foo": "PATCH /channels/:id",
+		"DELETE /v1/channels/foo/subscriptions/bar": "DELETE /channels/:id/subscriptions/:target",
+		"GET /v1/channels/foo/bar": "unmatched",
+		"GET /v1/users/foo": "unmatched",
+		"BREW /v1/channels": "unmatched",
+	}
+	for request, route := range expected {
+		parts := strings.SplitN(request, " ", 2)
+		r, err := http.NewRequest(parts[0], "http://localhost" + parts[1], nil)
+		if err != nil {
+			t.Fatalf("Unexpected error when creating request: %v", err)
+		}
+		if label := streambot.RouteLabel(r, "/v1/"); label != route {
+			t.Fatalf("Expected route label `%s` for `%s`, given `%s`", route, request, label)
+		}
+	}
+}
+
+func TestAPIRecordsRequestMetrics(t *testing.T) {
+	registry := streambot.NewPrometheusRegistry("streambot")
+	db := new(DatabaseMock)
+	db.MissingChannelId = "foobar"
+	a := streambot.NewAPI(db, streambot.NewStatter(registry))
+	errChan := make(chan error)
+	a.Serve(8096, "/v1/", errChan)
+	go func() {
+		t.Fatalf("Unexpected error occurred when starting API: %v", <- errChan)
+	}()
+	res, err := http.Get("http://localhost:8096/v1/channels/foobar")
+	if err != nil {
+		t.Fatalf("Unexpected error on executing GET request: %v", err)
+	}
+	res.Body.Close()
+	// Scrape the metrics the way Prometheus does
+	w := httptest.NewRecorder()
+	registry.ServeHTTP(w, nil)
+	out := w.Body.String()
+	expected := []string{
+		"streambot_http_requests_total{route=\"GET /channels/:id\",status=\"404\"} 1\n",
+		"streambot_http_requests_duration_seconds_count{route=\"GET /channels/:id\",status=\"404\"} 1\n",
+		"streambot_db_duration_seconds_count{method=\"GetChannelWithUid\"} 1\n",
+		"streambot_db_errors_total{method=\"GetChannelWithUid\"} 1\n",
+		"streambot_http_responses_bytes_count{route=\"GET /channels/:id\",status=\"404\"} 1\n",
+	}
+	for _, line := range expected {
+		if !strings.Contains(out, line) {
+			t.Fatalf("Expected metrics to contain `%s`, given:\n%s", line, out)
+		}
+	}
+	a.Shutdown()
+	<- a.Closed
+	fmt.Println("Done")
+}
\ No newline at end of file
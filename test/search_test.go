@@ -0,0 +1,245 @@
+package main
+
+import (
+	"testing"
+	"net/http"
+	"encoding/json"
+	"../src/streambot"
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"fmt"
+	"net/http/httptest"
+)
+
+func TestTokenize(t *testing.T) {
+	expected := []string{"jazz", "blues", "2015", "café"}
+	if words := streambot.Tokenize("Jazz & Blues -- 2015, café!"); !reflect.DeepEqual(words, expected) {
+		t.Fatalf("Expected words %v, given %v", expected, words)
+	}
+}
+
+func SearchResultIds(results []streambot.SearchResult) []string {
+	ids := make([]string, len(results))
+	for i, result := range results {
+		ids[i] = result.Channel.Id
+	}
+	return ids
+}
+
+func TestSearchIndexRanksMatches(t *testing.T) {
+	index := streambot.NewSearchIndex()
+	index.Index(streambot.Channel{Id: "a", Name: "Rock classics", Description: "Jazz now and then"})
+	index.Index(streambot.Channel{Id: "b", Name: "Jazz standards"})
+	index.Index(streambot.Channel{Id: "c", Name: "Jazzy lounge", Description: "Late night"})
+	index.Index(streambot.Channel{Id: "d", Name: "Classical", Description: "Rock free"})
+	page := streambot.Page{0, 10}
+	// Matches in the name rank above matches in the description, exact above prefix matches
+	if ids := SearchResultIds(index.Search("JAZZ", page)); !reflect.DeepEqual(ids, []string{"b", "c", "a"}) {
+		t.Fatalf("Expected search results [b c a], given %v", ids)
+	}
+	// All words of the query have to match
+	if ids := SearchResultIds(index.Search("rock class", page)); !reflect.DeepEqual(ids, []string{"a", "d"}) {
+		t.Fatalf("Expected search results [a d], given %v", ids)
+	}
+	if ids := SearchResultIds(index.Search("jazz class", streambot.Page{0, 1})); !reflect.DeepEqual(ids, []string{"a"}) {
+		t.Fatalf("Expected search results [a], given %v", ids)
+	}
+	if results := index.Search("  !", page); len(results) != 0 {
+		t.Fatalf("Expected no search results for query without words, given %v", results)
+	}
+	// Replaced and removed Channels are not found by their former words anymore
+	index.Index(streambot.Channel{Id: "b", Name: "Standards"})
+	index.Remove("c")
+	if ids := SearchResultIds(index.Search("jaz", page)); !reflect.DeepEqual(ids, []string{"a"}) {
+		t.Fatalf("Expected search results [a] after update and removal, given %v", ids)
+	}
+	if index.Len() != 3 {
+		t.Fatalf("Expected 3 indexed Channels, given %d", index.Len())
+	}
+}
+
+func TestSearchIndexedDatabaseMaintainsIndex(t *testing.T) {
+	memory := streambot.NewMemoryDatabase()
+	index := streambot.NewSearchIndex()
+	db := streambot.NewSearchIndexedDatabase(memory, index)
+	ch := streambot.NewChannel("Morning news")
+	if err := db.SaveChannel(ch); err != nil {
+		t.Fatalf("Unexpected error when saving Channel: %v", err)
+	}
+	if ids := SearchResultIds(index.Search("news", streambot.Page{0, 10})); !reflect.DeepEqual(ids, []string{ch.Id}) {
+		t.Fatalf("Expected saved Channel to be found, given %v", ids)
+	}
+	name := "Evening news"
+	if err, _ := db.UpdateChannel(ch.Id, 0, streambot.ChannelPatch{Name: &name}); err != nil {
+		t.Fatalf("Unexpected error when updating Channel: %v", err)
+	}
+	if results := index.Search("morning", streambot.Page{0, 10}); len(results) != 0 {
+		t.Fatalf("Expected updated Channel not to be found by former name, given %v", results)
+	}
+	if err := db.DeleteChannel(ch.Id, 0); err != nil {
+		t.Fatalf("Unexpected error when deleting Channel: %v", err)
+	}
+	if index.Len() != 0 {
+		t.Fatalf("Expected deleted Channel to be removed from index, given %d Channels", index.Len())
+	}
+	// A cold index is rebuilt from the Channels in the database
+	for i := 0; i < streambot.MaxPageLimit + 1; i++ {
+		if err := memory.SaveChannel(streambot.NewChannel(fmt.Sprintf("news %d", i))); err != nil {
+			t.Fatalf("Unexpected error when saving Channel: %v", err)
+		}
+	}
+	if err := index.Rebuild(memory); err != nil {
+		t.Fatalf("Unexpected error when rebuilding index: %v", err)
+	}
+	if index.Len() != streambot.MaxPageLimit + 1 {
+		t.Fatalf("Expected %d Channels in rebuilt index, given %d", streambot.MaxPageLimit + 1, index.Len())
+	}
+}
+
+// Lists the Channels of the decorated Database and then writes to the index, as if Channels were 
+// written through the API while a rebuild lists them
+type WritingDuringListDatabase struct {
+	streambot.Database
+	Write func()
+}
+
+func (db WritingDuringListDatabase) ListChannels(
+	filter streambot.ChannelFilter, order streambot.ChannelSort, page streambot.Page,
+) (err error, chs []streambot.Channel) {
+	err, chs = db.Database.ListChannels(filter, order, page)
+	db.Write()
+	return
+}
+
+func TestRebuildSearchIndexKeepsChannelsWrittenMeanwhile(t *testing.T) {
+	memory := streambot.NewMemoryDatabase()
+	index := streambot.NewSearchIndex()
+	db := streambot.NewSearchIndexedDatabase(memory, index)
+	deleted := streambot.NewChannel("Deleted news")
+	updated := streambot.NewChannel("Morning news")
+	for _, ch := range []*streambot.Channel{deleted, updated} {
+		if err := db.SaveChannel(ch); err != nil {
+			t.Fatalf("Unexpected error when saving Channel: %v", err)
+		}
+	}
+	created := streambot.NewChannel("Created news")
+	err := index.Rebuild(WritingDuringListDatabase{memory, func() {
+		if err := db.SaveChannel(created); err != nil {
+			t.Fatalf("Unexpected error when saving Channel: %v", err)
+		}
+		if err := db.DeleteChannel(deleted.Id, 0); err != nil {
+			t.Fatalf("Unexpected error when deleting Channel: %v", err)
+		}
+		name := "Evening news"
+		if err, _ := db.UpdateChannel(updated.Id, 0, streambot.ChannelPatch{Name: &name}); err != nil {
+			t.Fatalf("Unexpected error when updating Channel: %v", err)
+		}
+	}})
+	if err != nil {
+		t.Fatalf("Unexpected error when rebuilding index: %v", err)
+	}
+	// Channels created or updated meanwhile are kept, deleted ones are not brought back
+	if ids := SearchResultIds(index.Search("news created", streambot.Page{0, 10})); !reflect.DeepEqual(ids, []string{created.Id}) {
+		t.Fatalf("Expected Channel created during rebuild to be found, given %v", ids)
+	}
+	if ids := SearchResultIds(index.Search("evening", streambot.Page{0, 10})); !reflect.DeepEqual(ids, []string{updated.Id}) {
+		t.Fatalf("Expected Channel updated during rebuild to be found by its new name, given %v", ids)
+	}
+	if ids := SearchResultIds(index.Search("morning", streambot.Page{0, 10})); len(ids) != 0 {
+		t.Fatalf("Expected Channel updated during rebuild not to be found by its former name, given %v", ids)
+	}
+	if index.Len() != 2 {
+		t.Fatalf("Expected deleted Channel to stay removed after rebuild, given %d Channels", index.Len())
+	}
+}
+
+func TestRebuildSearchIndexHandler(t *testing.T) {
+	memory := streambot.NewMemoryDatabase()
+	for _, name := range []string{"Soul kitchen", "Country roads"} {
+		if err := memory.SaveChannel(streambot.NewChannel(name)); err != nil {
+			t.Fatalf("Unexpected error when saving Channel: %v", err)
+		}
+	}
+	index := streambot.NewSearchIndex()
+	handler := streambot.RebuildSearchIndexHandler(index, memory)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/search-index/rebuild", nil))
+	if rec.Code != http.StatusMethodNotAllowed || index.Len() != 0 {
+		t.Fatalf("Expected GET to be rejected without rebuilding, given status %d and %d Channels", 
+			rec.Code, index.Len())
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/search-index/rebuild", nil))
+	var out map[string]int
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("Unexpected error when unmarshalling response `%s`: %v", rec.Body.String(), err)
+	}
+	if rec.Code != http.StatusOK || out["channels"] != 2 || index.Len() != 2 {
+		t.Fatalf("Expected 2 Channels to be indexed, given status %d, response %v and %d Channels", 
+			rec.Code, out, index.Len())
+	}
+}
+
+type SearchChannelsResponse struct {
+	Channels 	[]GetChannelResponse 	`json:"channels"`
+	NextCursor 	string 					`json:"next_cursor"`
+}
+
+func TestAPISearchChannels(t *testing.T) {
+	// Channels existing before the API starts are only found once the index is rebuilt
+	db := streambot.NewMemoryDatabase()
+	if err := db.SaveChannel(streambot.NewChannel("Soulful mornings")); err != nil {
+		t.Fatalf("Unexpected error when saving Channel: %v", err)
+	}
+	a := streambot.NewAPI(db, NewTestStatter(t))
+	if err := a.Channels.SearchIndex.Rebuild(db); err != nil {
+		t.Fatalf("Unexpected error when rebuilding index: %v", err)
+	}
+	errChan := make(chan error)
+	a.Serve(8095, "/v1/", errChan)
+	go func() {
+		t.Fatalf("Unexpected error occurred when starting API: %v", <- errChan)
+	}()
+	// Channels created through the API are indexed right away
+	cli := &http.Client{}
+	for _, name := range []string{"Soul kitchen", "Country roads"} {
+		b, _ := json.Marshal(map[string]string{"name": name})
+		req, err := http.NewRequest("PUT", "http://localhost:8095/v1/channels", bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("Unexpected error when creating PUT request: %v", err)
+		}
+		res, err := cli.Do(req)
+		if err != nil {
+			t.Fatalf("Unexpected error on executing Channel PUT request: %v", err)
+		}
+		res.Body.Close()
+	}
+	url := "http://localhost:8095/v1/channels/search?q=soul"
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("Unexpected error on executing GET request on URL `%s`: %v", url, err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	var out SearchChannelsResponse
+	if err = json.Unmarshal(body, &out); err != nil {
+		t.Fatalf("Unexpected error when unmarshalling JSON response `%s`: %v", string(body), err)
+	}
+	if len(out.Channels) != 2 || out.Channels[0].Name != "Soul kitchen" || 
+		out.Channels[1].Name != "Soulful mornings" {
+		t.Fatalf("Expected exact match `Soul kitchen` before `Soulful mornings`, given %v", out)
+	}
+	// Queries without any word are rejected
+	res, err = http.Get("http://localhost:8095/v1/channels/search?q=%20")
+	if err != nil {
+		t.Fatalf("Unexpected error on executing GET request: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 400 {
+		t.Fatalf("Expected status code 400 for empty search query, given %d", res.StatusCode)
+	}
+	a.Shutdown()
+	<- a.Closed
+	fmt.Println("Done")
+}
\ No newline at end of file
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+	"errors"
+	"../src/streambot"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TempSQLDatabase(t *testing.T) (dir string, db *streambot.SQLDatabase) {
+	dir, err := ioutil.TempDir("", "streambot")
+	if err != nil {
+		t.Fatalf("Unexpected error when creating temporary data directory: %v", err)
+	}
+	db, err = streambot.NewSQLDatabase("sqlite3", filepath.Join(dir, "streambot.sqlite"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Unexpected error when opening SQL database: %v", err)
+	}
+	return
+}
+
+func TestSQLDatabaseGraphSemantics(t *testing.T) {
+	dir, db := TempSQLDatabase(t)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+	VerifyDatabaseGraphSemantics(t, db)
+}
+
+func TestSQLDatabaseMigratesOnlyOnce(t *testing.T) {
+	dir, db := TempSQLDatabase(t)
+	defer os.RemoveAll(dir)
+	ch := streambot.NewChannel("foo")
+	if err := db.SaveChannel(ch); err != nil {
+		t.Fatalf("Unexpected error when saving Channel: %v", err)
+	}
+	db.Close()
+	// Reopening runs the schema bootstrap again, which must keep the existing data
+	db, err := streambot.NewSQLDatabase("sqlite3", filepath.Join(dir, "streambot.sqlite"))
+	if err != nil {
+		t.Fatalf("Unexpected error when reopening SQL database: %v", err)
+	}
+	defer db.Close()
+	err, saved := db.GetChannelWithUid(ch.Id)
+	if err != nil || saved.Name != ch.Name {
+		t.Fatalf("Expected Channel `%s` to be persisted, given %v with error %v", ch.Name, saved, err)
+	}
+}
+
+func TestSQLDatabaseDeletesChannelWithoutVersion(t *testing.T) {
+	dir, db := TempSQLDatabase(t)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+	ch := streambot.NewChannel("foo")
+	if err := db.SaveChannel(ch); err != nil {
+		t.Fatalf("Unexpected error when saving Channel: %v", err)
+	}
+	name := "bar"
+	if err, _ := db.UpdateChannel(ch.Id, 0, streambot.ChannelPatch{Name: &name}); err != nil {
+		t.Fatalf("Unexpected error when updating Channel without version: %v", err)
+	}
+	if err := db.DeleteChannel(ch.Id, 0); err != nil {
+		t.Fatalf("Unexpected error when deleting Channel without version: %v", err)
+	}
+	if err, _ := db.GetChannelWithUid(ch.Id); !errors.Is(err, streambot.ErrChannelNotFound) {
+		t.Fatalf("Expected deleted Channel to be gone, given %v", err)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"testing"
+	"net"
+	"net/http"
+	"fmt"
+	"strings"
+	"time"
+	"../src/streambot"
+)
+
+// Statter of API servers under test, its metrics are dropped unless a local StatsD daemon runs
+func NewTestStatter(t *testing.T) *streambot.Statter {
+	statter, err := streambot.NewStatsDStatter(streambot.StatterOptions{Host: "localhost", Prefix: "test"})
+	if err != nil {
+		t.Fatalf("Unexpected error when creating statter: %v", err)
+	}
+	return statter
+}
+
+// Listens for StatsD metrics on a random local port
+func ListenStatsD(t *testing.T) (conn net.PacketConn, port int) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error when listening for metrics: %v", err)
+	}
+	return conn, conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+// Reads metric packets until none arrived for a while
+func ReadStatsDPackets(conn net.PacketConn) (packets []string) {
+	buf := make([]byte, 65536)
+	for {
+		conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		packets = append(packets, string(buf[:n]))
+	}
+}
+
+func ReadStatsDLines(conn net.PacketConn) (lines []string) {
+	for _, packet := range ReadStatsDPackets(conn) {
+		lines = append(lines, strings.Split(strings.TrimSpace(packet), "\n")...)
+	}
+	return
+}
+
+func TestStatterSendsToConfiguredTargetWithPrefix(t *testing.T) {
+	conn, port := ListenStatsD(t)
+	defer conn.Close()
+	statter, err := streambot.NewStatsDStatter(streambot.StatterOptions{
+		Host: "127.0.0.1", 
+		Port: port, 
+		Prefix: "streambot",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error when creating statter: %v", err)
+	}
+	statter.Count("channels.get")
+	statter.Time("db", 12 * time.Millisecond, streambot.Label{"method", "GetChannelWithUid"})
+	statter.Time("db", 1500 * time.Microsecond, streambot.Label{"method", "ListChannels"})
+	statter.Count("http.requests", streambot.Label{"route", "GET /channels/:id"}, streambot.Label{"status", "200"})
+	lines := ReadStatsDLines(conn)
+	// Label values are appended to the key and durations sent in fractional milliseconds
+	expected := []string{
+		"streambot.channels.get:1|c", 
+		"streambot.db.GetChannelWithUid:12|ms", 
+		"streambot.db.ListChannels:1.5|ms", 
+		"streambot.http.requests.GET_channels_id.200:1|c",
+	}
+	if len(lines) != len(expected) || lines[0] != expected[0] || lines[1] != expected[1] || 
+		lines[2] != expected[2] || lines[3] != expected[3] {
+		t.Fatalf("Expected metrics %v, given %v", expected, lines)
+	}
+}
+
+func TestStatterSamplesMetrics(t *testing.T) {
+	conn, port := ListenStatsD(t)
+	defer conn.Close()
+	statter, err := streambot.NewStatsDStatter(streambot.StatterOptions{
+		Host: "127.0.0.1", 
+		Port: port, 
+		Prefix: "streambot",
+		SampleRate: 0.5,
+		SampleRates: map[string]float64{"channels.put": 1},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error when creating statter: %v", err)
+	}
+	for i := 0; i < 200; i++ {
+		statter.Count("channels.get")
+	}
+	statter.Count("channels.put")
+	lines := ReadStatsDLines(conn)
+	if len(lines) < 2 || len(lines) > 200 || lines[len(lines) - 1] != "streambot.channels.put:1|c" {
+		t.Fatalf("Expected about half of the sampled metrics and the unsampled one, given %d", len(lines))
+	}
+	for _, line := range lines[:len(lines) - 1] {
+		if line != "streambot.channels.get:1|c|@0.5" {
+			t.Fatalf("Expected sampled metric with rate suffix, given `%s`", line)
+		}
+	}
+}
+
+func TestStatsDSinkSamplesLabelledMetricsByKey(t *testing.T) {
+	conn, port := ListenStatsD(t)
+	defer conn.Close()
+	sink, err := streambot.NewStatsDSink(streambot.StatterOptions{
+		Host: "127.0.0.1", 
+		Port: port, 
+		Prefix: "streambot",
+		SampleRate: 0.01,
+		SampleRates: map[string]float64{"channels.put": 1},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error when creating sink: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		sink.Count("channels.put", []streambot.Label{{"status", "201"}})
+	}
+	sink.Close()
+	var lines []string
+	for _, packet := range ReadStatsDPackets(conn) {
+		lines = append(lines, strings.Split(strings.TrimSpace(packet), "\n")...)
+	}
+	if len(lines) != 10 {
+		t.Fatalf("Expected all labelled metrics to be sent at the rate of their key, given %d", len(lines))
+	}
+	for _, line := range lines {
+		if line != "streambot.channels.put.201:1|c" {
+			t.Fatalf("Expected unsampled labelled metric, given `%s`", line)
+		}
+	}
+}
+
+func TestStatsDSinkBatchesMetricsIntoPackets(t *testing.T) {
+	conn, port := ListenStatsD(t)
+	defer conn.Close()
+	sink, err := streambot.NewStatsDSink(streambot.StatterOptions{
+		Host: "127.0.0.1", 
+		Port: port, 
+		Prefix: "streambot",
+		MaxPacketSize: 512,
+		FlushInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error when creating sink: %v", err)
+	}
+	statter := streambot.NewStatter(sink)
+	for i := 0; i < 50; i++ {
+		statter.Count("channels.get")
+	}
+	// Closing sends the metrics still waiting for the flush interval
+	statter.Close()
+	packets := ReadStatsDPackets(conn)
+	var lines []string
+	for _, packet := range packets {
+		if len(packet) > 512 {
+			t.Fatalf("Expected packets of at most 512 bytes, given %d bytes", len(packet))
+		}
+		lines = append(lines, strings.Split(strings.TrimSpace(packet), "\n")...)
+	}
+	if len(lines) != 50 || len(packets) < 2 || len(packets) > 5 {
+		t.Fatalf("Expected 50 metrics in a few packets, given %d in %d packets", len(lines), len(packets))
+	}
+}
+
+func TestStatsDSinkDropsMetricsInsteadOfBlocking(t *testing.T) {
+	// Nobody reads from the pipe, so that sending the first packet blocks forever
+	conn, _ := net.Pipe()
+	sink, err := streambot.NewStatsDSinkWithConn(conn, streambot.StatterOptions{
+		Prefix: "streambot",
+		BufferSize: 2,
+		MaxPacketSize: 1,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error when creating sink: %v", err)
+	}
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 100; i++ {
+			sink.Count("channels.get", nil)
+		}
+		done <- true
+	}()
+	select {
+	case <- done:
+	case <- time.After(time.Second):
+		t.Fatalf("Expected metrics not to block while StatsD does not take them")
+	}
+	if sink.Dropped() < 90 {
+		t.Fatalf("Expected most metrics to be dropped, given %d dropped", sink.Dropped())
+	}
+}
+
+func TestStatsDSinkDropsMetricsAfterClose(t *testing.T) {
+	conn, port := ListenStatsD(t)
+	defer conn.Close()
+	sink, err := streambot.NewStatsDSink(streambot.StatterOptions{Host: "127.0.0.1", Port: port})
+	if err != nil {
+		t.Fatalf("Unexpected error when creating sink: %v", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Unexpected error when closing sink: %v", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Expected closing sink again to do nothing, given %v", err)
+	}
+	// Requests finishing during shutdown still record metrics
+	sink.Count("channels.get", nil)
+	if sink.Dropped() != 1 {
+		t.Fatalf("Expected metric recorded after close to be dropped, given %d dropped", sink.Dropped())
+	}
+}
+
+func TestAPIWithoutStats(t *testing.T) {
+	// A nil Statter drops metrics as well
+	var statter *streambot.Statter
+	statter.Count("channels.get")
+	statter.Close()
+	db := new(DatabaseMock)
+	a := streambot.NewAPI(db, nil)
+	errChan := make(chan error)
+	a.Serve(8097, "/v1/", errChan)
+	go func() {
+		t.Fatalf("Unexpected error occurred when starting API: %v", <- errChan)
+	}()
+	res, err := http.Get("http://localhost:8097/v1/channels/foobar")
+	if err != nil {
+		t.Fatalf("Unexpected error on executing GET request: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Fatalf("Expected status code 200 without metrics, given %d", res.StatusCode)
+	}
+	a.Shutdown()
+	<- a.Closed
+	fmt.Println("Done")
+}
\ No newline at end of file
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+	"errors"
+	"time"
+	"../src/streambot"
+)
+
+// Stats remembering the recorded metrics by key and label values
+type StatsRecorder struct {
+	Counts 	map[string]int
+	Times 	map[string]int
+}
+
+func NewStatsRecorder() *StatsRecorder {
+	return &StatsRecorder{make(map[string]int), make(map[string]int)}
+}
+
+func StatsRecorderKey(key string, labels []streambot.Label) string {
+	for _, label := range labels {
+		key += "." + label.Value
+	}
+	return key
+}
+
+func (s *StatsRecorder) Count(key string, labels ...streambot.Label) {
+	s.Counts[StatsRecorderKey(key, labels)]++
+}
+
+func (s *StatsRecorder) Time(key string, val time.Duration, labels ...streambot.Label) {
+	s.Times[StatsRecorderKey(key, labels)]++
+}
+
+func (s *StatsRecorder) Size(key string, bytes int, labels ...streambot.Label) {}
+
+func TestTimedDatabaseTimesSuccessfulAndFailedCalls(t *testing.T) {
+	stats := NewStatsRecorder()
+	db := streambot.NewTimedDatabase(streambot.NewMemoryDatabase(), stats)
+	ch := streambot.NewChannel("foo")
+	if err := db.SaveChannel(ch); err != nil {
+		t.Fatalf("Unexpected error when saving Channel: %v", err)
+	}
+	if err, _ := db.GetChannelWithUid("unknown"); !errors.Is(err, streambot.ErrChannelNotFound) {
+		t.Fatalf("Expected ErrChannelNotFound to be passed through, given %v", err)
+	}
+	if err := db.DeleteChannel(ch.Id, 0); err != nil {
+		t.Fatalf("Unexpected error when deleting Channel: %v", err)
+	}
+	for _, key := range []string{"db.SaveChannel", "db.GetChannelWithUid", "db.DeleteChannel"} {
+		if stats.Times[key] != 1 {
+			t.Fatalf("Expected one timing of `%s`, given %v", key, stats.Times)
+		}
+	}
+	if len(stats.Counts) != 1 || stats.Counts["db.errors.GetChannelWithUid"] != 1 {
+		t.Fatalf("Expected only the failed call to be counted as error, given %v", stats.Counts)
+	}
+}
\ No newline at end of file